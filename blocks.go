@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// shownBlock returns s with the language and code of its block i, where 0
+// is its own, so the detail view can draw and copy any of them the same
+// way.
+func shownBlock(s snippet, i int) snippet {
+	if i <= 0 || i > len(s.Blocks) {
+		return s
+	}
+	s.Language, s.Code = s.Blocks[i-1].Language, s.Blocks[i-1].Code
+	return s
+}
+
+// blockTabs lists s's languages for the detail view, with the shown one
+// picked out. Snippets in one language have no tabs.
+func blockTabs(s snippet, active int) string {
+	if len(s.Blocks) == 0 {
+		return ""
+	}
+	var tabs []string
+	for i, b := range s.AllBlocks() {
+		lang := b.Language
+		switch {
+		case b.Corrupt:
+			lang = "⚠ damaged"
+		case lang == "":
+			lang = "(none)"
+		}
+		if i == active {
+			tabs = append(tabs, selectedItemStyle.UnsetPaddingLeft().Render("["+lang+"]"))
+		} else {
+			tabs = append(tabs, placeholderStyle.Render(" "+lang+" "))
+		}
+	}
+	return placeholderStyle.PaddingLeft(4).Render(strings.Join(tabs, " ")) + "\n"
+}
+
+// showBlock switches the detail view to block i, starting at its top.
+func (m *model) showBlock(i int) {
+	m.block = i
+	m.lineCursor = 0
+	m.selectAnchor = -1
+}
+
+// openBlock starts adding the detail view's snippet in another language:
+// first the language, then its code.
+func (m model) openBlock() (tea.Model, tea.Cmd) {
+	m.navigate("block")
+	m.input.Placeholder = "Language"
+	m.input.SetValue("")
+	m.textarea.SetValue("")
+	return m, m.input.Focus()
+}
+
+// updateBlock handles keys while adding a language to a snippet.
+func (m model) updateBlock(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	i := m.findSnippet(m.detailID)
+	if i < 0 {
+		return m.resetState(), nil
+	}
+	var cmd tea.Cmd
+	if m.input.Focused() {
+		if !key.Matches(msg, keys.Submit) {
+			m.input, cmd = m.input.Update(msg)
+			return m, cmd
+		}
+		lang := strings.TrimSpace(m.input.Value())
+		if lang == "" {
+			return m, nil
+		}
+		for _, b := range m.snippets[i].AllBlocks() {
+			if strings.EqualFold(strings.TrimSpace(b.Language), lang) {
+				m.err = fmt.Errorf("%q already has a %s version", m.snippets[i].Name, lang)
+				return m, nil
+			}
+		}
+		m.newSnippet.Language = lang
+		m.input.Blur()
+		return m, m.textarea.Focus()
+	}
+	if !key.Matches(msg, keys.Save) {
+		m.textarea, cmd = m.textarea.Update(msg)
+		return m, cmd
+	}
+	if strings.TrimSpace(m.textarea.Value()) == "" {
+		return m, nil
+	}
+	m.snippets[i].Blocks = append(m.snippets[i].Blocks, store.Block{Language: m.newSnippet.Language, Code: m.textarea.Value()})
+	m.newSnippet = snippet{}
+	m.textarea.Blur()
+	cmd = m.persist()
+	m = m.back()
+	m.showBlock(len(m.snippets[i].Blocks))
+	return m, cmd
+}
+
+// blockView draws the screen for adding a language to a snippet.
+func (m model) blockView() string {
+	name := ""
+	if i := m.findSnippet(m.detailID); i >= 0 {
+		name = m.snippets[i].Name
+	}
+	var s strings.Builder
+	s.WriteString(m.renderTitle("Add a Language to " + name))
+	s.WriteString("\n\n")
+	if m.input.Focused() {
+		s.WriteString(itemStyle.Render(fmt.Sprintf("Enter language:\n%s\n", m.input.View())))
+	} else {
+		s.WriteString(itemStyle.Render(fmt.Sprintf("Enter the %s code:\n%s\n", m.newSnippet.Language, m.textarea.View())))
+	}
+	s.WriteString(m.keyHints())
+	return s.String()
+}
+
+// removeBlock drops the detail view's shown block, after asking. A
+// snippet's own language can't be removed this way, since the others are
+// versions of it.
+func (m model) removeBlock() (tea.Model, tea.Cmd) {
+	i := m.findSnippet(m.detailID)
+	if i < 0 {
+		return m, nil
+	}
+	if m.block == 0 {
+		m.status = "Only the other languages can be removed; switch to one with tab"
+		return m, nil
+	}
+	shown := shownBlock(m.snippets[i], m.block)
+	return m.ask(confirmation{
+		prompt: fmt.Sprintf("Remove the %s version of %q?", shown.Language, shown.Name),
+		onYes: func(m model) (tea.Model, tea.Cmd) {
+			if i := m.findSnippet(m.detailID); i >= 0 && m.block <= len(m.snippets[i].Blocks) {
+				blocks := m.snippets[i].Blocks
+				m.snippets[i].Blocks = append(blocks[:m.block-1:m.block-1], blocks[m.block:]...)
+			}
+			m.showBlock(0)
+			cmd := m.persist()
+			return m, cmd
+		},
+	})
+}
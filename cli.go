@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// runCLI handles the non-interactive subcommands. It reports false when
+// args don't name one, in which case main goes on to start the TUI.
+func runCLI(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "version", "--version":
+		fmt.Println(versionString())
+		return true, nil
+	case "export":
+		return true, runExport(args[1:])
+	case "bundle":
+		return true, runBundle(args[1:])
+	case "serve":
+		return true, runServe(args[1:])
+	case "get":
+		return true, runGet(args[1:])
+	case "import":
+		return true, runImport(args[1:])
+	case "quick":
+		return true, runQuick(args[1:])
+	case "seed":
+		return true, runSeed(args[1:])
+	case "dump":
+		return true, runDump(args[1:])
+	case "load":
+		return true, runLoad(args[1:])
+	}
+	return false, nil
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "md", "output format: md, shell or files")
+	tmpl := fs.String("template", "", "render with this text/template file instead of a built in format")
+	out := fs.String("out", "", "file to write to (default stdout), or the directory for --format files")
+	collection := fs.String("collection", "", "collection to export (default the default collection)")
+	dryRun := fs.Bool("dry-run", false, "with --format files, list the files each snippet would be written as and write nothing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Check what to write before --out is created, so a typo in the
+	// format doesn't truncate the file it names
+	var export func(w io.Writer, snippets []snippet) error
+	switch {
+	case *tmpl != "":
+		t, err := parseExportTemplate(*tmpl)
+		if err != nil {
+			return err
+		}
+		export = func(w io.Writer, snippets []snippet) error {
+			return t.Execute(w, snippets)
+		}
+	case *format == "md", *format == "markdown":
+		export = exportMarkdown
+	case *format == "shell", *format == "sh":
+		export = exportShell
+	case *format != "files":
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	snippets, err := loadCollection(*collection)
+	if err != nil {
+		return err
+	}
+
+	if export == nil {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			for i, name := range exportFilenames(snippets, cfg.Extensions) {
+				fmt.Printf("%d\t%s\n", snippets[i].ID, filepath.Join(*out, name))
+			}
+			return nil
+		}
+		if *out == "" {
+			return fmt.Errorf("export --format files needs --out <dir>")
+		}
+		return exportFiles(*out, snippets, cfg.Extensions)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", *out, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	return export(w, snippets)
+}
+
+func runBundle(args []string) error {
+	fs := flag.NewFlagSet("bundle", flag.ContinueOnError)
+	lang := fs.String("language", "", "language whose snippets to combine (required)")
+	out := fs.String("out", "", "file to write to (default stdout)")
+	copyOut := fs.Bool("copy", false, "copy the result to the clipboard instead of printing it")
+	collection := fs.String("collection", "", "collection to read (default the default collection)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *lang == "" {
+		return fmt.Errorf("bundle needs --language")
+	}
+
+	snippets, err := loadCollection(*collection)
+	if err != nil {
+		return err
+	}
+	text, n := bundleLanguage(snippets, *lang)
+	if n == 0 {
+		return fmt.Errorf("no %s snippets found", *lang)
+	}
+	switch {
+	case *copyOut:
+		if err := copyToClipboard(text); err != nil {
+			return fmt.Errorf("failed to copy: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Copied %d %s snippets\n", n, *lang)
+		return nil
+	case *out != "":
+		if err := os.WriteFile(*out, []byte(text), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", *out, err)
+		}
+		return nil
+	}
+	_, err = fmt.Print(text)
+	return err
+}
+
+// runImport adds the given files to a collection as new snippets.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	lang := fs.String("language", "", "language for every imported snippet (default from each file's extension)")
+	tags := fs.String("tags", "", "comma separated tags to give every imported snippet")
+	collection := fs.String("collection", "", "collection to import into (default the default collection)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: snipsnap import [--language lang] [--tags t1,t2] <file or dir>...")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	imported, err := importFiles(fs.Args(), cfg.Extensions)
+	if err != nil {
+		return err
+	}
+	backend, err := openStore(*collection, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer closeStore(backend)
+	snippets, version, err := backend.Load()
+	if err != nil {
+		return err
+	}
+	next := max(backend.NextID(), store.NextID(snippets))
+	for _, s := range imported {
+		s.ID = next
+		next++
+		if *lang != "" {
+			s.Language = *lang
+		}
+		s.Tags = store.ParseTags(*tags)
+		s.UID = store.NewUID()
+		snippets = append(snippets, s)
+	}
+	if _, err := backend.Save(snippets, version); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d snippets into %s\n", len(imported), backend.Location())
+	return nil
+}
+
+// runQuick saves a one-liner given as name=code, splitting on the first
+// =, e.g. snipsnap quick "deploy=kubectl apply -f .".
+func runQuick(args []string) error {
+	fs := flag.NewFlagSet("quick", flag.ContinueOnError)
+	lang := fs.String("language", "text", "language of the snippet")
+	collection := fs.String("collection", "", "collection to add to (default the default collection)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// Unquoted input arrives split on spaces
+	name, code, ok := strings.Cut(strings.Join(fs.Args(), " "), "=")
+	name, code = strings.TrimSpace(name), strings.TrimSpace(code)
+	if !ok || name == "" || code == "" {
+		return fmt.Errorf("usage: snipsnap quick [--language lang] <name>=<code>")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	backend, err := openStore(*collection, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer closeStore(backend)
+	snippets, version, err := backend.Load()
+	if err != nil {
+		return err
+	}
+	s := snippet{
+		ID:        max(backend.NextID(), store.NextID(snippets)),
+		Name:      name,
+		Language:  *lang,
+		Code:      code,
+		CreatedAt: time.Now(),
+		UID:       store.NewUID(),
+	}
+	if _, err := backend.Save(append(snippets, s), version); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Added %q as snippet %d\n", s.Name, s.ID)
+	return nil
+}
+
+// runDump writes a whole collection to stdout as JSON, for load to read
+// back, e.g. on another machine.
+func runDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	collection := fs.String("collection", "", "collection to dump (default the default collection)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	snippets, err := loadCollection(*collection)
+	if err != nil {
+		return err
+	}
+	return dumpSnippets(os.Stdout, snippets)
+}
+
+// runLoad reads a dump from stdin into a collection.
+func runLoad(args []string) error {
+	fs := flag.NewFlagSet("load", flag.ContinueOnError)
+	replace := fs.Bool("replace", false, "replace the collection with the dump instead of merging it in")
+	collection := fs.String("collection", "", "collection to load into (default the default collection)")
+	yes := yesFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	loaded, err := readDump(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	backend, err := openStore(*collection, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer closeStore(backend)
+	snippets, version, err := backend.Load()
+	if err != nil {
+		return err
+	}
+	if *replace && len(snippets) > 0 {
+		prompt := fmt.Sprintf("Replace the %d snippets in %s with the %d in the dump?", len(snippets), backend.Location(), len(loaded))
+		if err := confirmCLI(prompt, *yes); err != nil {
+			return err
+		}
+	}
+	snippets = loadSnippets(snippets, loaded, backend.NextID(), *replace)
+	store.AssignUIDs(snippets)
+	if _, err := backend.Save(snippets, version); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Loaded %d snippets into %s\n", len(loaded), backend.Location())
+	return nil
+}
+
+// yesFlag adds --yes, and -y for short, to a command that asks before
+// destroying anything.
+func yesFlag(fs *flag.FlagSet) *bool {
+	yes := fs.Bool("yes", false, "don't ask before replacing or deleting snippets")
+	fs.BoolVar(yes, "y", false, "short for --yes")
+	return yes
+}
+
+// ttyPath is where this platform's controlling terminal can be opened,
+// whatever stdin has been redirected from.
+func ttyPath() string {
+	if runtime.GOOS == "windows" {
+		return "CONIN$"
+	}
+	return "/dev/tty"
+}
+
+// confirmCLI asks prompt on the terminal and fails unless it is answered
+// yes. With yes set it doesn't ask. The answer is read from the terminal
+// itself rather than stdin, which may be a dump being piped in. Without a
+// terminal to ask on it fails straight away, so scripts don't hang
+// waiting for an answer.
+func confirmCLI(prompt string, yes bool) error {
+	if yes {
+		return nil
+	}
+	tty, err := os.Open(ttyPath())
+	if err != nil || !term.IsTerminal(int(tty.Fd())) {
+		if tty != nil {
+			tty.Close()
+		}
+		return fmt.Errorf("%q needs an answer but there is no terminal to ask on; pass --yes to go ahead", prompt)
+	}
+	defer tty.Close()
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errors.New("cancelled")
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	collection := fs.String("collection", "", "collection to serve (default the default collection)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	backend, err := openCollection(*collection, cfg.Storage, true)
+	if err != nil {
+		return err
+	}
+	defer closeStore(backend)
+	return serve(*addr, backend)
+}
+
+// runGet prints one snippet's code and nothing else, so it can be piped
+// straight into another command.
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	noNewline := fs.Bool("no-newline", false, "don't add a trailing newline when the code lacks one")
+	lang := fs.String("language", "", "print the snippet's code in this language, for snippets kept in several")
+	collection := fs.String("collection", "", "collection to read (default the default collection)")
+	// Accept the ID before or after the flags
+	var idArg string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		idArg, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if idArg == "" && fs.NArg() > 0 {
+		idArg = fs.Arg(0)
+	}
+	if idArg == "" {
+		return fmt.Errorf("usage: snipsnap get <id or uid> [--no-newline]")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	backend, err := openCollection(*collection, cfg.Storage, true)
+	if err != nil {
+		return err
+	}
+	defer closeStore(backend)
+	s, err := lookupSnippet(backend, idArg)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no snippet with ID or UID %s", idArg)
+	}
+	if err != nil {
+		return err
+	}
+	if s.Corrupt {
+		return fmt.Errorf("the stored code of snippet %d is damaged and couldn't be decoded", s.ID)
+	}
+	code := s.Code
+	if *lang != "" {
+		found := false
+		for _, b := range s.AllBlocks() {
+			if strings.EqualFold(b.Language, *lang) {
+				code, found = b.Code, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("snippet %d has no %s version", s.ID, *lang)
+		}
+	}
+	if !*noNewline && !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	_, err = io.WriteString(os.Stdout, code)
+	return err
+}
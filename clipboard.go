@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// copyToClipboard places text on the system clipboard.
+func copyToClipboard(text string) error {
+	return clipboard.WriteAll(text)
+}
+
+// readClipboard returns the current contents of the system clipboard.
+func readClipboard() (string, error) {
+	return clipboard.ReadAll()
+}
+
+// codeLines returns lines start to end, inclusive and 0-based, of code.
+// Copies are always cut from the stored code like this rather than from
+// what a screen renders, so wrapping, masking or line numbers in the
+// display can never end up on the clipboard.
+func codeLines(code string, start, end int) string {
+	lines := strings.Split(code, "\n")
+	start, end = max(start, 0), min(end, len(lines)-1)
+	if start > end {
+		return ""
+	}
+	return strings.Join(lines[start:end+1], "\n")
+}
+
+// withNameHeader puts a comment naming s, in its language's comment
+// syntax, above code, for copies meant to be pasted into a file. The
+// stored code never has it.
+func withNameHeader(s snippet, code string) string {
+	return commentLine(s.Language, "from snipsnap: "+s.Name) + "\n" + code
+}
+
+// copyField copies one field of a snippet and reports how it went, so
+// every "copy X" action reads the same way.
+func (m *model) copyField(what, text string) {
+	if err := copyToClipboard(text); err != nil {
+		m.err = fmt.Errorf("copy failed: %w", err)
+		return
+	}
+	m.status = fmt.Sprintf("Copied %s", what)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// defaultCollection is the name shown for the original snippetsFile.
+const defaultCollection = "default"
+
+// dataDir holds one file per named collection: snipsnap in the user's
+// config directory, e.g. ~/.config/snipsnap on Linux, so the same
+// collections open wherever snipsnap is started from.
+func dataDir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("can't find a data directory for collections: %w", err)
+	}
+	return filepath.Join(base, "snipsnap"), nil
+}
+
+// collectionPath returns the file a collection is stored in: <name>.txt
+// in dataDir, in the same line format as the snippets file. The default
+// collection keeps using snippetsFile so existing setups carry on working.
+func collectionPath(name string) (string, error) {
+	if name == "" || name == defaultCollection {
+		return snippetsFile, nil
+	}
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".txt"), nil
+}
+
+// databasePath is where the sqlite storage keeps the collection whose
+// file is path: alongside it, with a .db extension.
+func databasePath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".db"
+}
+
+// exists reports whether anything is at path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// openStore opens a collection with the given storage, one of the
+// storage* values. A sqlite store is filled from the collection's file
+// the first time it is opened, and snippets saved before UIDs existed
+// are given theirs.
+func openStore(collection, storage string) (store.Store, error) {
+	return openCollection(collection, storage, false)
+}
+
+// openCollection is openStore, except that with readOnly it writes
+// nothing: missing UIDs stay missing, and a collection not yet copied
+// into sqlite is read from its file instead.
+func openCollection(collection, storage string, readOnly bool) (store.Store, error) {
+	path, err := collectionPath(collection)
+	if err != nil {
+		return nil, err
+	}
+	if readOnly && storage == storageSQLite && !exists(databasePath(path)) {
+		storage = storageFile
+	}
+	var backend store.Store
+	switch storage {
+	case storageFile:
+		backend = store.NewFile(path)
+	case storageSQLite:
+		db, err := store.OpenSQLite(databasePath(path))
+		if err != nil {
+			return nil, err
+		}
+		backend = db
+		if readOnly {
+			return backend, nil
+		}
+		if err := db.MigrateFile(path); err != nil {
+			db.Close()
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown storage %q", storage)
+	}
+	if readOnly {
+		return backend, nil
+	}
+	if err := migrateUIDs(backend); err != nil {
+		closeStore(backend)
+		return nil, err
+	}
+	return backend, nil
+}
+
+// migrateUIDs saves backend once with a UID on every snippet, if any
+// lacks one. A collection with nothing in it isn't written, so opening
+// one doesn't create its file.
+func migrateUIDs(backend store.Store) error {
+	snippets, version, err := backend.Load()
+	if err != nil || !store.AssignUIDs(snippets) {
+		// A load error is left for the caller's own load to report
+		return nil
+	}
+	if _, err := backend.Save(snippets, version); err != nil {
+		return fmt.Errorf("failed to give %s UIDs: %w", backend.Location(), err)
+	}
+	return nil
+}
+
+// loadCollection reads every snippet in a collection with the configured
+// storage, for commands that only need them once. It opens the collection
+// read-only, so reading never rewrites a file a running TUI has open.
+func loadCollection(collection string) ([]snippet, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	backend, err := openCollection(collection, cfg.Storage, true)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStore(backend)
+	snippets, _, err := backend.Load()
+	return snippets, err
+}
+
+// getSnippet fetches one snippet from backend. Stores that can do so
+// decode only that snippet's code rather than the whole library's.
+func getSnippet(backend store.Store, id int) (snippet, error) {
+	if lazy, ok := backend.(store.Lazy); ok {
+		return lazy.Get(id)
+	}
+	snippets, _, err := backend.Load()
+	if err != nil {
+		return snippet{}, err
+	}
+	for _, s := range snippets {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return snippet{}, store.ErrNotFound
+}
+
+// lookupSnippet fetches the snippet ref names, which is either its ID or its
+// UID. A UID can be shortened to any prefix that only one snippet has.
+func lookupSnippet(backend store.Store, ref string) (snippet, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return getSnippet(backend, id)
+	}
+	load := backend.Load
+	if lazy, ok := backend.(store.Lazy); ok {
+		// Only the match's code is needed
+		load = lazy.LoadMeta
+	}
+	snippets, _, err := load()
+	if err != nil {
+		return snippet{}, err
+	}
+	var found []snippet
+	for _, s := range snippets {
+		if s.UID == ref {
+			found = []snippet{s}
+			break
+		}
+		if ref != "" && strings.HasPrefix(s.UID, ref) {
+			found = append(found, s)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return snippet{}, store.ErrNotFound
+	case 1:
+		return getSnippet(backend, found[0].ID)
+	}
+	return snippet{}, fmt.Errorf("%q matches %d snippets; give more of the UID", ref, len(found))
+}
+
+// closeStore releases backend if it holds anything open.
+func closeStore(backend store.Store) {
+	if c, ok := backend.(interface{ Close() error }); ok {
+		c.Close()
+	}
+}
+
+// listCollections returns the default collection followed by every named
+// collection found on disk, alphabetically, whichever storage holds it.
+func listCollections() []string {
+	var names []string
+	dir, err := dataDir()
+	if err != nil {
+		return []string{defaultCollection}
+	}
+	for _, pattern := range []string{"*.txt", "*.db"} {
+		files, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, f := range files {
+			name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return append([]string{defaultCollection}, names...)
+}
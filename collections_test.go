@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCollectionsDontDependOnWorkingDir(t *testing.T) {
+	inTempDir(t)
+	want, err := collectionPath("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filepath.IsAbs(want) {
+		t.Fatalf("collection path %q is relative to the working directory", want)
+	}
+	backend, err := openStore("work", storageFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := backend.Save([]snippet{{ID: 1, UID: "a", Name: "deploy", Code: "make deploy"}}, backend.Version())
+	if err != nil || version == 0 {
+		t.Fatalf("save: %v", err)
+	}
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := collectionPath("work"); got != want {
+		t.Errorf("from another directory the collection is at %q, want %q", got, want)
+	}
+	snippets, err := loadCollection("work")
+	if err != nil || len(snippets) != 1 || snippets[0].Name != "deploy" {
+		t.Errorf("from another directory work holds %+v, %v", snippets, err)
+	}
+	if got := listCollections(); !reflect.DeepEqual(got, []string{defaultCollection, "work"}) {
+		t.Errorf("listCollections = %v", got)
+	}
+	if got, _ := collectionPath(defaultCollection); got != snippetsFile {
+		t.Errorf("default collection is at %q, want %q", got, snippetsFile)
+	}
+}
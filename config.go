@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const configFile = "config.json"
+
+// Values accepted for config.SaveMode.
+const (
+	saveModeImmediate = "immediate"
+	saveModeDebounce  = "debounce"
+	saveModeManual    = "manual"
+)
+
+// Values accepted for config.Storage.
+const (
+	storageFile   = "file"
+	storageSQLite = "sqlite"
+)
+
+// config holds user preferences read from configFile. Any key that is
+// missing from the file keeps its default.
+type config struct {
+	// SaveMode controls when changes to the snippets are written out.
+	// "immediate" (the default) rewrites the file after every change,
+	// "debounce" waits for a short pause and writes once, and "manual"
+	// holds changes until Ctrl+S or quit.
+	SaveMode string `json:"saveMode"`
+
+	// Extensions maps a language to the file extension used for it, on
+	// top of the built in ones, e.g. {"python": ".py3"}. Import reads it
+	// the other way round to tell a file's language from its extension.
+	Extensions map[string]string `json:"extensions"`
+
+	// SortMode is the order snippet lists are shown in: "added" (the
+	// default), "name", "language", "newest", "recent" (last copied
+	// first) or "rating" (most stars first). 's' in the view changes it
+	// and writes the choice back here.
+	SortMode string `json:"sortMode"`
+
+	// DefaultLanguage fills in the language step of the add flow, so
+	// Enter takes it as it is. It can still be edited. Empty by default.
+	DefaultLanguage string `json:"defaultLanguage"`
+
+	// Theme names the color preset the TUI is drawn in: "default",
+	// "nord", "gruvbox", "solarized" or "mono". 'T' in the view cycles
+	// through them and writes the choice back here.
+	Theme string `json:"theme"`
+
+	// LanguageColors draws snippet names in a color picked from their
+	// language. It is on by default; 'C' in the view toggles it.
+	LanguageColors bool `json:"languageColors"`
+
+	// LineNumbers numbers the lines of code in the view and detail
+	// screens. 'l' on either toggles it.
+	LineNumbers bool `json:"lineNumbers"`
+
+	// StaleDays is how long a snippet has to go without being copied to
+	// show up in the Stale Snippets report. It defaults to 90.
+	StaleDays int `json:"staleDays"`
+
+	// Confirm asks before deleting, merging, changing tags across
+	// snippets and quitting with unsaved changes. It is on by default;
+	// turning it off goes ahead without asking.
+	Confirm bool `json:"confirm"`
+
+	// Storage picks where snippets are kept: "file" (the default), the
+	// line based snippets file, or "sqlite", a database next to it that
+	// only writes what changed. The first time a collection is opened
+	// with sqlite its snippets are copied over from the file.
+	Storage string `json:"storage"`
+
+	// RunLanguages lists the languages whose snippets R may copy and
+	// run, e.g. ["sh", "bash"]. It is empty by default, so nothing runs
+	// until it is asked for. Only shell languages can be listed.
+	RunLanguages []string `json:"runLanguages"`
+
+	// Collections holds settings that only apply to the named collection.
+	Collections map[string]collectionConfig `json:"collections"`
+}
+
+// collectionConfig is the per collection part of config. Everything in it
+// is off unless switched on.
+type collectionConfig struct {
+	// TrimTrailingWhitespace strips spaces and tabs from the end of every
+	// line of code when it is saved.
+	TrimTrailingWhitespace bool `json:"trimTrailingWhitespace"`
+
+	// FinalNewline makes saved code end in exactly one newline.
+	FinalNewline bool `json:"finalNewline"`
+}
+
+// collection returns the settings for the named collection.
+func (c config) collection(name string) collectionConfig {
+	return c.Collections[name]
+}
+
+// cleanCode applies cc's whitespace settings to code.
+func (cc collectionConfig) cleanCode(code string) string {
+	if cc.TrimTrailingWhitespace {
+		lines := strings.Split(code, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \t\r")
+		}
+		code = strings.Join(lines, "\n")
+	}
+	if cc.FinalNewline && code != "" {
+		code = strings.TrimRight(code, "\n") + "\n"
+	}
+	return code
+}
+
+func defaultConfig() config {
+	return config{
+		SaveMode:       saveModeImmediate,
+		SortMode:       sortAdded,
+		Theme:          themes[0].name,
+		LanguageColors: true,
+		Storage:        storageFile,
+		StaleDays:      90,
+		Confirm:        true,
+	}
+}
+
+func loadConfig() (config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(configFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse %s: %v", configFile, err)
+	}
+
+	switch cfg.SaveMode {
+	case saveModeImmediate, saveModeDebounce, saveModeManual:
+	default:
+		return cfg, fmt.Errorf("unknown saveMode %q in %s", cfg.SaveMode, configFile)
+	}
+
+	switch cfg.SortMode {
+	case sortAdded, sortName, sortLanguage, sortNewest, sortRecent, sortRating:
+	default:
+		return cfg, fmt.Errorf("unknown sortMode %q in %s", cfg.SortMode, configFile)
+	}
+
+	if _, ok := findTheme(cfg.Theme); !ok {
+		return cfg, fmt.Errorf("unknown theme %q in %s", cfg.Theme, configFile)
+	}
+
+	if cfg.StaleDays < 1 {
+		return cfg, fmt.Errorf("staleDays in %s must be at least 1", configFile)
+	}
+
+	switch cfg.Storage {
+	case storageFile, storageSQLite:
+	default:
+		return cfg, fmt.Errorf("unknown storage %q in %s", cfg.Storage, configFile)
+	}
+
+	for i, lang := range cfg.RunLanguages {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if _, ok := runInterpreters[lang]; !ok {
+			return cfg, fmt.Errorf("runLanguages in %s lists %q, but only sh, bash and zsh snippets can be run", configFile, cfg.RunLanguages[i])
+		}
+		cfg.RunLanguages[i] = lang
+	}
+
+	// Match languages the way snippets are looked up and accept
+	// extensions written with or without the dot
+	exts := make(map[string]string, len(cfg.Extensions))
+	for lang, ext := range cfg.Extensions {
+		ext = strings.TrimSpace(ext)
+		if ext == "" {
+			return cfg, fmt.Errorf("empty extension for %q in %s", lang, configFile)
+		}
+		exts[strings.ToLower(strings.TrimSpace(lang))] = "." + strings.TrimPrefix(ext, ".")
+	}
+	cfg.Extensions = exts
+	return cfg, nil
+}
+
+// saveConfigValue sets one top level key in configFile, creating the file
+// if needed. The other keys are carried over as they are rather than the
+// file being rewritten from config, so defaults don't get pinned into it.
+func saveConfigValue(key string, value any) error {
+	settings := map[string]json.RawMessage{}
+	data, err := os.ReadFile(configFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", configFile, err)
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	settings[key] = encoded
+	data, err = json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configFile, append(data, '\n'), 0644)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// confirmation is a yes/no question shown over the current screen. While
+// one is pending it takes every key; onYes runs on 'y', and onNo, if set,
+// tidies up on 'n' or Esc.
+type confirmation struct {
+	prompt string
+	onYes  func(m model) (tea.Model, tea.Cmd)
+	onNo   func(m model) model
+}
+
+// ask puts a confirmation up over the current screen. Every yes/no
+// question goes through here, so with confirmations switched off in the
+// config it answers yes straight away.
+func (m model) ask(c confirmation) (tea.Model, tea.Cmd) {
+	if !m.cfg.Confirm {
+		return c.onYes(m)
+	}
+	m.confirm = &c
+	return m, nil
+}
+
+// answerConfirm resolves the pending confirmation from msg. Keys other
+// than yes, no and Esc are ignored so a stray keystroke can't slip through
+// to the screen underneath.
+func (m model) answerConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := *m.confirm
+	switch {
+	case key.Matches(msg, keys.Yes):
+		m.confirm = nil
+		return c.onYes(m)
+	case key.Matches(msg, keys.No, keys.Back):
+		m.confirm = nil
+		if c.onNo != nil {
+			m = c.onNo(m)
+		}
+	}
+	return m, nil
+}
+
+func (m model) confirmView() string {
+	hints := joinHints([]key.Binding{keys.Yes, keys.No})
+	return confirmStyle.Render(m.confirm.prompt+"\n"+hints) + "\n"
+}
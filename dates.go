@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the absolute date formats accepted by the date filter.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"2006-01-02 15:04",
+	time.RFC3339,
+	"Jan 2 2006",
+	"2 Jan 2006",
+}
+
+// dateRange is an inclusive range of creation times. A zero From or To
+// leaves that end open.
+type dateRange struct {
+	From, To time.Time
+	label    string
+}
+
+func (r dateRange) contains(t time.Time) bool {
+	if t.IsZero() {
+		return false
+	}
+	if !r.From.IsZero() && t.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && t.After(r.To) {
+		return false
+	}
+	return true
+}
+
+func (r dateRange) String() string {
+	return r.label
+}
+
+// parseDateRange understands relative ranges like "last 7d", "2w" or "12h"
+// (d, w, h, m for months and y for years), a single date meaning that
+// whole day, and "from..to" where either side may be left out.
+func parseDateRange(s string, now time.Time) (dateRange, error) {
+	s = strings.TrimSpace(s)
+	r := dateRange{label: s}
+
+	relative := strings.TrimSpace(strings.TrimPrefix(s, "last "))
+	if from, ok := parseRelative(relative, now); ok {
+		r.From = from
+		return r, nil
+	}
+
+	if from, to, ok := strings.Cut(s, ".."); ok {
+		var err error
+		if from = strings.TrimSpace(from); from != "" {
+			if r.From, err = parseDate(from); err != nil {
+				return r, err
+			}
+		}
+		if to = strings.TrimSpace(to); to != "" {
+			day, err := parseDate(to)
+			if err != nil {
+				return r, err
+			}
+			r.To = endOfDay(day)
+		}
+		if r.From.IsZero() && r.To.IsZero() {
+			return r, fmt.Errorf("%q needs a date on at least one side of '..'", s)
+		}
+		if !r.To.IsZero() && r.To.Before(r.From) {
+			return r, fmt.Errorf("%q ends before it starts", s)
+		}
+		return r, nil
+	}
+
+	day, err := parseDate(s)
+	if err != nil {
+		return r, err
+	}
+	r.From, r.To = day, endOfDay(day)
+	return r, nil
+}
+
+// parseRelative parses "<n><unit>" into the time that far before now.
+func parseRelative(s string, now time.Time) (time.Time, bool) {
+	if len(s) < 2 {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return time.Time{}, false
+	}
+	switch s[len(s)-1] {
+	case 'h':
+		return now.Add(-time.Duration(n) * time.Hour), true
+	case 'd':
+		return now.AddDate(0, 0, -n), true
+	case 'w':
+		return now.AddDate(0, 0, -7*n), true
+	case 'm':
+		return now.AddDate(0, -n, 0), true
+	case 'y':
+		return now.AddDate(-n, 0, 0), true
+	}
+	return time.Time{}, false
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't read %q as a date, try 2006-01-02 or 7d", s)
+}
+
+func endOfDay(t time.Time) time.Time {
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
@@ -0,0 +1,62 @@
+package main
+
+import "strings"
+
+// diffOp says which side of a diff a line came from.
+type diffOp int
+
+const (
+	diffSame diffOp = iota
+	diffRemoved
+	diffAdded
+)
+
+type diffLine struct {
+	Op   diffOp
+	Text string
+}
+
+// diffLines returns a line diff turning a into b, using the longest common
+// subsequence of lines. It is quadratic, which is fine at snippet sizes.
+func diffLines(a, b string) []diffLine {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	// lcs[i][j] is the LCS length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []diffLine
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, diffLine{diffSame, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{diffRemoved, x[i]})
+			i++
+		default:
+			out = append(out, diffLine{diffAdded, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, diffLine{diffRemoved, x[i]})
+	}
+	for ; j < len(y); j++ {
+		out = append(out, diffLine{diffAdded, y[j]})
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// dumpedSnippet is a snippet as dump writes it and load reads it. It is
+// kept apart from store.Snippet so the JSON keys don't change when that
+// does.
+type dumpedSnippet struct {
+	ID        int           `json:"id"`
+	UID       string        `json:"uid,omitempty"`
+	Name      string        `json:"name"`
+	Language  string        `json:"language"`
+	Code      string        `json:"code"`
+	Blocks    []dumpedBlock `json:"blocks,omitempty"`
+	Tags      []string      `json:"tags,omitempty"`
+	Pinned    bool          `json:"pinned,omitempty"`
+	Sensitive bool          `json:"sensitive,omitempty"`
+	Archived  bool          `json:"archived,omitempty"`
+	Rating    int           `json:"rating,omitempty"`
+	CreatedAt *time.Time    `json:"created,omitempty"`
+	UsedAt    *time.Time    `json:"used,omitempty"`
+	Source    string        `json:"source,omitempty"`
+	Notes     string        `json:"notes,omitempty"`
+	Corrupt   bool          `json:"corrupt,omitempty"`
+	BadNotes  bool          `json:"notesCorrupt,omitempty"`
+}
+
+// dumpedBlock is one of a snippet's extra languages in a dump.
+type dumpedBlock struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+	Corrupt  bool   `json:"corrupt,omitempty"`
+}
+
+// dumpSnippets writes snippets to w as a JSON array.
+func dumpSnippets(w io.Writer, snippets []snippet) error {
+	dumped := make([]dumpedSnippet, len(snippets))
+	for i, s := range snippets {
+		dumped[i] = dumpedSnippet{
+			ID: s.ID, UID: s.UID, Name: s.Name, Language: s.Language, Code: s.Code,
+			Tags: s.Tags, Pinned: s.Pinned, Sensitive: s.Sensitive, Archived: s.Archived, Rating: s.Rating,
+			CreatedAt: optionalTime(s.CreatedAt), UsedAt: optionalTime(s.LastUsedAt), Source: s.Source, Notes: s.Notes, Corrupt: s.Corrupt, BadNotes: s.NotesCorrupt,
+		}
+		for _, b := range s.Blocks {
+			dumped[i].Blocks = append(dumped[i].Blocks, dumpedBlock{b.Language, b.Code, b.Corrupt})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dumped)
+}
+
+// readDump reads snippets written by dumpSnippets from r.
+func readDump(r io.Reader) ([]snippet, error) {
+	var dumped []dumpedSnippet
+	if err := json.NewDecoder(r).Decode(&dumped); err != nil {
+		return nil, fmt.Errorf("failed to read dump: %v", err)
+	}
+	snippets := make([]snippet, len(dumped))
+	for i, d := range dumped {
+		if !store.ValidRating(d.Rating) {
+			return nil, fmt.Errorf("failed to read dump: snippet %d has rating %d, not 0 to %d", d.ID, d.Rating, store.MaxRating)
+		}
+		snippets[i] = snippet{
+			ID: d.ID, UID: d.UID, Name: d.Name, Language: d.Language, Code: d.Code,
+			Tags: d.Tags, Pinned: d.Pinned, Sensitive: d.Sensitive, Archived: d.Archived, Rating: d.Rating,
+			Source: d.Source, Notes: d.Notes, Corrupt: d.Corrupt, NotesCorrupt: d.BadNotes,
+		}
+		for _, b := range d.Blocks {
+			snippets[i].Blocks = append(snippets[i].Blocks, store.Block{Language: b.Language, Code: b.Code, Corrupt: b.Corrupt})
+		}
+		if d.CreatedAt != nil {
+			snippets[i].CreatedAt = *d.CreatedAt
+		}
+		if d.UsedAt != nil {
+			snippets[i].LastUsedAt = *d.UsedAt
+		}
+	}
+	return snippets, nil
+}
+
+// optionalTime is t, or nil for the zero time so it is left out.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// loadSnippets combines loaded with existing: loaded snippets with the
+// UID of an existing one replace it, keeping its ID, and the rest are
+// added. Only UIDs say two snippets are the same, since a dump of a
+// collection that never had them, or one written by hand, has IDs that
+// mean nothing here; so without replace a loaded snippet with no UID is
+// always added under a new ID. With replace, loaded is all that is kept,
+// under its own IDs where they don't clash. New IDs go from next on.
+func loadSnippets(existing, loaded []snippet, next int, replace bool) []snippet {
+	if replace {
+		existing = nil
+	}
+	next = max(next, store.NextID(existing), store.NextID(loaded))
+	merged := append([]snippet(nil), existing...)
+	for _, s := range loaded {
+		if s.UID != "" {
+			if i := slices.IndexFunc(merged, func(d snippet) bool { return d.UID == s.UID }); i >= 0 {
+				s.ID = merged[i].ID
+				merged[i] = s
+				continue
+			}
+		}
+		if (s.UID == "" && !replace) || slices.ContainsFunc(merged, func(d snippet) bool { return d.ID == s.ID }) {
+			s.ID = next
+			next++
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
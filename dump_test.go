@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadSnippets(t *testing.T) {
+	existing := []snippet{
+		{ID: 1, UID: "u1", Name: "one", Code: "echo one"},
+		{ID: 2, UID: "u2", Name: "two", Code: "echo two"},
+		{ID: 3, UID: "u3", Name: "three", Code: "echo three"},
+	}
+	tests := []struct {
+		name    string
+		dump    string
+		replace bool
+		want    []snippet
+	}{
+		{
+			"dump without UIDs is added, not merged by ID",
+			`[{"id": 1, "name": "theirs", "language": "sh", "code": "ls"}, {"id": 3, "name": "other", "language": "sh", "code": "pwd"}]`,
+			false,
+			append(existing[:3:3],
+				snippet{ID: 4, Name: "theirs", Language: "sh", Code: "ls"},
+				snippet{ID: 5, Name: "other", Language: "sh", Code: "pwd"}),
+		},
+		{
+			"same UID replaces and keeps the local ID",
+			`[{"id": 9, "uid": "u2", "name": "two again", "language": "", "code": "echo 2"}]`,
+			false,
+			[]snippet{existing[0], {ID: 2, UID: "u2", Name: "two again", Code: "echo 2"}, existing[2]},
+		},
+		{
+			"new UID with a taken ID gets a new one",
+			`[{"id": 1, "uid": "new", "name": "new", "language": "", "code": "x"}]`,
+			false,
+			append(existing[:3:3], snippet{ID: 4, UID: "new", Name: "new", Code: "x"}),
+		},
+		{
+			"replace keeps the dump's own IDs",
+			`[{"id": 1, "name": "a", "language": "", "code": "a"}, {"id": 1, "name": "b", "language": "", "code": "b"}]`,
+			true,
+			[]snippet{{ID: 1, Name: "a", Code: "a"}, {ID: 4, Name: "b", Code: "b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := readDump(strings.NewReader(tt.dump))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := loadSnippets(append([]snippet(nil), existing...), loaded, 4, tt.replace)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadSnippets:\n got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	snippets := []snippet{
+		{ID: 1, UID: "u1", Name: "one", Language: "sh", Code: "echo one", Tags: []string{"a"}, Rating: 2},
+		{ID: 4, Name: "no uid", Code: "x", Notes: "a note"},
+	}
+	var buf bytes.Buffer
+	if err := dumpSnippets(&buf, snippets); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readDump(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, snippets) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, snippets)
+	}
+}
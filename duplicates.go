@@ -0,0 +1,28 @@
+package main
+
+import "strings"
+
+// normalizeCode is code as compared for duplicates: line endings, trailing
+// whitespace on each line and blank lines around it don't count.
+func normalizeCode(code string) string {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// findDuplicate returns the first snippet whose code matches code once
+// both are normalized. Empty code never matches.
+func findDuplicate(snippets []snippet, code string) (snippet, bool) {
+	code = normalizeCode(code)
+	if code == "" {
+		return snippet{}, false
+	}
+	for _, s := range snippets {
+		if normalizeCode(s.Code) == code {
+			return s, true
+		}
+	}
+	return snippet{}, false
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// exportMarkdown writes every snippet as a section of a single Markdown
+// document, with its code in a fenced block tagged by language, one per
+// language it has.
+func exportMarkdown(w io.Writer, snippets []snippet) error {
+	if _, err := fmt.Fprintln(w, "# Snippets"); err != nil {
+		return err
+	}
+	for _, s := range snippets {
+		fmt.Fprintf(w, "\n## %s\n\n", s.Name)
+		if len(s.Tags) > 0 {
+			fmt.Fprintf(w, "Tags: %s\n\n", strings.Join(s.Tags, ", "))
+		}
+		for i, b := range s.AllBlocks() {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fence := codeFence(b.Code)
+			if _, err := fmt.Fprintf(w, "%s%s\n%s\n%s\n", fence, b.Language, strings.TrimSuffix(b.Code, "\n"), fence); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// codeFence returns a backtick fence long enough that no run of backticks
+// inside code can close it early.
+func codeFence(code string) string {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// templateFuncs are available to user export templates on top of the
+// text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join":    strings.Join,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+	"fence":   codeFence,
+	"comment": commentLine,
+}
+
+// parseExportTemplate reads the text/template in path for export to render
+// snippets through. The template's dot is the full []snippet, so it usually
+// ranges over it.
+func parseExportTemplate(path string) (*template.Template, error) {
+	t, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+	return t, nil
+}
+
+// shellLanguages are the snippet languages exportShell turns into functions.
+var shellLanguages = map[string]bool{"bash": true, "sh": true, "shell": true, "zsh": true}
+
+// exportShell writes a sourceable script with one snip_<slug> function per
+// shell snippet. Snippets in other languages are listed as skipped.
+func exportShell(w io.Writer, snippets []snippet) error {
+	if _, err := fmt.Fprintln(w, "# Generated by snipsnap export --format shell"); err != nil {
+		return err
+	}
+	for _, s := range snippets {
+		if !shellLanguages[strings.ToLower(s.Language)] {
+			fmt.Fprintf(w, "\n# skipped %q: language %q is not a shell\n", s.Name, s.Language)
+			continue
+		}
+		body := strings.TrimRight(s.Code, "\n")
+		if _, err := fmt.Fprintf(w, "\n# %s\nsnip_%s() {\n%s\n}\n", s.Name, slug(s.Name, '_'), body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slug lowercases name and replaces every run of characters other than
+// letters and digits with sep.
+func slug(name string, sep rune) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if pending && b.Len() > 0 {
+				b.WriteRune(sep)
+			}
+			pending = false
+			b.WriteRune(r)
+		} else {
+			pending = true
+		}
+	}
+	if b.Len() == 0 {
+		return "snippet"
+	}
+	return b.String()
+}
+
+// snippetFilename is the file a snippet is written as: its name with
+// everything but letters and digits turned into dashes, and the extension
+// for its language.
+func snippetFilename(s snippet, exts map[string]string) string {
+	return slug(s.Name, '-') + extensionFor(s.Language, exts)
+}
+
+// exportFilenames names the file for each snippet, in order. Names that
+// would clash get the snippet ID appended, and then a count as well if
+// another snippet is already named like that, e.g. one called "foo 3".
+func exportFilenames(snippets []snippet, exts map[string]string) []string {
+	names := make([]string, len(snippets))
+	used := map[string]bool{}
+	for i, s := range snippets {
+		name := snippetFilename(s, exts)
+		for n := 1; used[name]; n++ {
+			suffix := fmt.Sprintf("-%d", s.ID)
+			if n > 1 {
+				suffix += fmt.Sprintf("-%d", n)
+			}
+			name = slug(s.Name, '-') + suffix + extensionFor(s.Language, exts)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// exportFiles writes each snippet to its own file in dir, named by
+// exportFilenames.
+func exportFiles(dir string, snippets []snippet, exts map[string]string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for i, name := range exportFilenames(snippets, exts) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(snippets[i].Code), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bundleLanguage joins the code of every snippet in lang into one text,
+// each headed by its name as a comment and separated by a blank line. It
+// also reports how many snippets went in.
+func bundleLanguage(snippets []snippet, lang string) (string, int) {
+	var parts []string
+	for _, s := range snippets {
+		if !s.HasLanguage(lang) {
+			continue
+		}
+		parts = append(parts, commentLine(s.Language, s.Name)+"\n"+strings.TrimRight(s.Code, "\n"))
+	}
+	if len(parts) == 0 {
+		return "", 0
+	}
+	return strings.Join(parts, "\n\n") + "\n", len(parts)
+}
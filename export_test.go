@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExportBadFormatLeavesOutAlone(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown format", []string{"--format", "bogus"}},
+		{"missing template", []string{"--template", "missing.tmpl"}},
+		{"broken template", []string{"--template", "broken.tmpl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTempDir(t)
+			if err := os.WriteFile("broken.tmpl", []byte("{{range}"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile("notes.md", []byte("keep me\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := runExport(append(tt.args, "--out", "notes.md")); err == nil {
+				t.Fatal("export succeeded")
+			}
+			if got, _ := os.ReadFile("notes.md"); string(got) != "keep me\n" {
+				t.Errorf("--out was overwritten with %q", got)
+			}
+			if err := runExport(append(tt.args, "--out", "new.md")); err == nil {
+				t.Fatal("export succeeded")
+			}
+			if exists("new.md") {
+				t.Error("--out was created")
+			}
+		})
+	}
+}
+
+func TestExportFilenames(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippets []snippet
+		want     []string
+	}{
+		{
+			"distinct names",
+			[]snippet{{ID: 1, Name: "Deploy App", Language: "sh"}, {ID: 2, Name: "query", Language: "sql"}},
+			[]string{"deploy-app.sh", "query.sql"},
+		},
+		{
+			"clash gets the ID",
+			[]snippet{{ID: 1, Name: "foo", Language: "sh"}, {ID: 3, Name: "Foo!", Language: "sh"}},
+			[]string{"foo.sh", "foo-3.sh"},
+		},
+		{
+			"ID suffix taken by a snippet named like it",
+			[]snippet{{ID: 1, Name: "foo-3", Language: "sh"}, {ID: 2, Name: "foo", Language: "sh"}, {ID: 3, Name: "foo", Language: "sh"}},
+			[]string{"foo-3.sh", "foo.sh", "foo-3-2.sh"},
+		},
+		{
+			"snippet named like an ID suffix already given out",
+			[]snippet{{ID: 1, Name: "foo", Language: "sh"}, {ID: 3, Name: "foo", Language: "sh"}, {ID: 4, Name: "foo 3", Language: "sh"}},
+			[]string{"foo.sh", "foo-3.sh", "foo-3-4.sh"},
+		},
+		{
+			"same name, different languages",
+			[]snippet{{ID: 1, Name: "hello", Language: "go"}, {ID: 2, Name: "hello", Language: "python"}},
+			[]string{"hello.go", "hello.py"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exportFilenames(tt.snippets, nil)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("exportFilenames = %q, want %q", got, tt.want)
+			}
+			seen := map[string]bool{}
+			for _, name := range got {
+				if seen[name] {
+					t.Errorf("%q given to two snippets", name)
+				}
+				seen[name] = true
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// findMatchStyle marks what the detail view's find matched.
+var findMatchStyle = lipgloss.NewStyle().Reverse(true)
+
+// findLines returns the indexes of the lines of code containing query,
+// ignoring case.
+func findLines(code, query string) []int {
+	if query == "" {
+		return nil
+	}
+	query = strings.ToLower(query)
+	var found []int
+	for i, line := range strings.Split(code, "\n") {
+		if strings.Contains(strings.ToLower(line), query) {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+// highlightMatches marks every occurrence of query in line, ignoring case.
+// Matches that the whitespace markers changed are left unmarked.
+func highlightMatches(line, query string, style lipgloss.Style) string {
+	if query == "" {
+		return line
+	}
+	lower, q := strings.ToLower(line), strings.ToLower(query)
+	if len(lower) != len(line) {
+		// Lowercasing changed the byte offsets, so they can't be trusted
+		return line
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(lower, q)
+		if i < 0 {
+			break
+		}
+		b.WriteString(line[:i] + style.Render(line[i:i+len(q)]))
+		line, lower = line[i+len(q):], lower[i+len(q):]
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// openFind starts a find in the open snippet's code. It shows the raw
+// code, since that is what is searched.
+func (m model) openFind() (tea.Model, tea.Cmd) {
+	m.find = textinput.New()
+	m.find.Prompt = "/"
+	m.find.Placeholder = "Find in this snippet"
+	m.find.PlaceholderStyle = placeholderStyle
+	m.find.TextStyle = inputStyle
+	m.find.SetValue(m.findQuery)
+	m.find.CursorEnd()
+	m.finding = true
+	m.rawMarkdown = true
+	return m, m.find.Focus()
+}
+
+// updateFind handles keys while the find input has focus; Esc is handled
+// with the rest of Esc. The cursor jumps to the first match at or after it
+// as the query is typed.
+func (m model) updateFind(msg tea.KeyMsg, code string) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyEnter {
+		m.finding = false
+		m.find.Blur()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.find, cmd = m.find.Update(msg)
+	if m.find.Value() != m.findQuery {
+		m.findQuery = m.find.Value()
+		matches := findLines(code, m.findQuery)
+		for _, line := range matches {
+			if line >= m.lineCursor {
+				m.lineCursor = line
+				return m, cmd
+			}
+		}
+		if len(matches) > 0 {
+			m.lineCursor = matches[0]
+		}
+	}
+	return m, cmd
+}
+
+// nextFind moves the cursor to the next match after it, or with back the
+// previous one before it, wrapping around at either end.
+func (m *model) nextFind(code string, back bool) {
+	matches := findLines(code, m.findQuery)
+	if len(matches) == 0 {
+		return
+	}
+	if back {
+		for i := len(matches) - 1; i >= 0; i-- {
+			if matches[i] < m.lineCursor {
+				m.lineCursor = matches[i]
+				return
+			}
+		}
+		m.lineCursor = matches[len(matches)-1]
+		return
+	}
+	for _, line := range matches {
+		if line > m.lineCursor {
+			m.lineCursor = line
+			return
+		}
+	}
+	m.lineCursor = matches[0]
+}
+
+// findPosition is the index among matches of the line the cursor is on,
+// or -1 if it isn't on one, for matchCount.
+func findPosition(matches []int, cursor int) int {
+	for i, line := range matches {
+		if line == cursor {
+			return i
+		}
+	}
+	return -1
+}
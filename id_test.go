@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// savedSnippets reads back what the default collection holds on disk.
+func savedSnippets(t *testing.T) []snippet {
+	t.Helper()
+	snippets, err := store.Load(snippetsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snippets
+}
+
+func ids(snippets []snippet) []int {
+	var ids []int
+	for _, s := range snippets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func TestDeletedIDsAreNotReused(t *testing.T) {
+	m := testModel(t, 80, 40, nil)
+	for _, name := range []string{"one", "two", "three"} {
+		m.newSnippet = snippet{Name: name, Language: "sh"}
+		m.textarea.SetValue("echo " + name)
+		next, _ := m.addSnippet()
+		m = next.(model)
+	}
+	if got := ids(savedSnippets(t)); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("saved IDs = %v, want [1 2 3]", got)
+	}
+
+	// Deleting the newest snippet doesn't free its ID, in this session or
+	// the next one
+	m.snippets = removeSnippet(m.snippets, 3)
+	if cmd := m.persist(); cmd != nil {
+		t.Fatalf("save failed: %v", cmd())
+	}
+	m.newSnippet = snippet{Name: "four", Language: "sh"}
+	m.textarea.SetValue("echo four")
+	next, _ := m.addSnippet()
+	m = next.(model)
+	if got := ids(savedSnippets(t)); !reflect.DeepEqual(got, []int{1, 2, 4}) {
+		t.Fatalf("saved IDs after delete and add = %v, want [1 2 4]", got)
+	}
+	m.snippets = removeSnippet(m.snippets, 4)
+	if cmd := m.persist(); cmd != nil {
+		t.Fatalf("save failed: %v", cmd())
+	}
+	if next := store.RecordedNextID(snippetsFile); next != 5 {
+		t.Errorf("recorded next ID = %d, want 5", next)
+	}
+
+	// Importing goes on from the recorded ID too
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.sh"), []byte("echo hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runImport([]string{dir}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	saved := savedSnippets(t)
+	if got := ids(saved); !reflect.DeepEqual(got, []int{1, 2, 5}) {
+		t.Fatalf("saved IDs after import = %v, want [1 2 5]", got)
+	}
+	uids := map[string]bool{}
+	for _, s := range saved {
+		if s.UID == "" || uids[s.UID] {
+			t.Errorf("snippet %d has a missing or repeated UID %q", s.ID, s.UID)
+		}
+		uids[s.UID] = true
+	}
+}
+
+func TestUnionSnippets(t *testing.T) {
+	tests := []struct {
+		name       string
+		disk, ours []snippet
+		want       []snippet
+	}{
+		{
+			"same UID, ours wins",
+			[]snippet{{ID: 1, UID: "a", Name: "disk"}},
+			[]snippet{{ID: 1, UID: "a", Name: "ours"}},
+			[]snippet{{ID: 1, UID: "a", Name: "ours"}},
+		},
+		{
+			"UID matches across different IDs",
+			[]snippet{{ID: 4, UID: "a", Name: "disk"}},
+			[]snippet{{ID: 2, UID: "a", Name: "ours"}},
+			[]snippet{{ID: 2, UID: "a", Name: "ours"}},
+		},
+		{
+			// Both sides deleted 3 and then added a snippet that got 4
+			"taken ID gets a new one",
+			[]snippet{{ID: 1, UID: "a"}, {ID: 4, UID: "disk"}},
+			[]snippet{{ID: 1, UID: "a"}, {ID: 4, UID: "ours"}},
+			[]snippet{{ID: 1, UID: "a"}, {ID: 4, UID: "disk"}, {ID: 10, UID: "ours"}},
+		},
+		{
+			"deleted on disk is kept",
+			[]snippet{{ID: 1, UID: "a"}},
+			[]snippet{{ID: 1, UID: "a"}, {ID: 2, UID: "b"}},
+			[]snippet{{ID: 1, UID: "a"}, {ID: 2, UID: "b"}},
+		},
+		{
+			"no UIDs fall back to IDs",
+			[]snippet{{ID: 1, Name: "disk"}, {ID: 2, Name: "other"}},
+			[]snippet{{ID: 1, Name: "ours"}},
+			[]snippet{{ID: 1, Name: "ours"}, {ID: 2, Name: "other"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := 10
+			got := unionSnippets(tt.disk, tt.ours, func() int {
+				next++
+				return next - 1
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unionSnippets:\n got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
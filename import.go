@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// importFiles reads each file in paths as a new snippet, named after the
+// file with its language worked out from the extension. A directory
+// imports the files directly inside it, which is what export --format
+// files writes. The snippets come back without IDs.
+func importFiles(paths []string, exts map[string]string) ([]snippet, error) {
+	var snippets []snippet
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			files = files[:0]
+			for _, e := range entries {
+				// Skip dotfiles, and folders since this isn't recursive
+				if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+					files = append(files, filepath.Join(path, e.Name()))
+				}
+			}
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			base := filepath.Base(file)
+			ext := filepath.Ext(base)
+			name := strings.TrimSuffix(base, ext)
+			if name == "" {
+				name = base
+			}
+			snippets = append(snippets, snippet{
+				Name:      name,
+				Language:  languageFor(ext, exts),
+				Code:      string(data),
+				CreatedAt: time.Now(),
+			})
+		}
+	}
+	if len(snippets) == 0 {
+		return nil, fmt.Errorf("no files to import")
+	}
+	return snippets, nil
+}
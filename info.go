@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// libraryInfo describes the collection for the Library Info screen: how
+// many snippets it has, how much code they hold and how big the file is.
+func libraryInfo(snippets []snippet, path string) string {
+	var code int
+	var pinned, sensitive, archived int
+	var noLanguage, noCode int
+	for _, s := range snippets {
+		if strings.TrimSpace(s.Language) == "" {
+			noLanguage++
+		}
+		if strings.TrimSpace(s.Code) == "" {
+			noCode++
+		}
+		code += len(s.Code)
+		if s.Pinned {
+			pinned++
+		}
+		if s.Sensitive {
+			sensitive++
+		}
+		if s.Archived {
+			archived++
+		}
+	}
+
+	onDisk := "not saved yet"
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		onDisk = formatBytes(info.Size())
+	case !errors.Is(err, os.ErrNotExist):
+		onDisk = "unknown (" + err.Error() + ")"
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return strings.Join([]string{
+		fmt.Sprintf("Snippets:  %d (%d pinned, %d sensitive, %d archived)", len(snippets), pinned, sensitive, archived),
+		fmt.Sprintf("Code:      %s", formatBytes(int64(code))),
+		fmt.Sprintf("Missing:   %d with no language, %d with no code (I in the view lists them)", noLanguage, noCode),
+		fmt.Sprintf("On disk:   %s", onDisk),
+		fmt.Sprintf("File:      %s", path),
+	}, "\n")
+}
+
+// isIncomplete reports whether s is missing its language or its code, so
+// the view can list the snippets that still need filling in.
+func isIncomplete(s snippet) bool {
+	return strings.TrimSpace(s.Language) == "" || strings.TrimSpace(s.Code) == ""
+}
+
+// countIncomplete is how many of snippets isIncomplete holds for.
+func countIncomplete(snippets []snippet) int {
+	n := 0
+	for _, s := range snippets {
+		if isIncomplete(s) {
+			n++
+		}
+	}
+	return n
+}
+
+// formatBytes renders n bytes in the largest unit that keeps it above 1.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// insightsLimit is how many commands the Insights screen ranks.
+const insightsLimit = 15
+
+// commandCount is one row of the Insights screen: a command and how many
+// shell snippets run it.
+type commandCount struct {
+	command  string
+	snippets int
+}
+
+// snippetCommands returns the distinct commands code runs: the first word
+// of each line, skipping comments, continuation lines, a leading sudo or
+// env assignments, and a pasted "$ " prompt.
+func snippetCommands(code string) []string {
+	var commands []string
+	seen := map[string]bool{}
+	continued := false
+	for _, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		wasContinued := continued
+		continued = strings.HasSuffix(line, "\\")
+		if wasContinued || line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words := strings.Fields(strings.TrimPrefix(line, "$ "))
+		for len(words) > 0 && (words[0] == "sudo" || strings.Contains(words[0], "=")) {
+			words = words[1:]
+		}
+		if len(words) == 0 || strings.HasPrefix(words[0], "{{") {
+			continue
+		}
+		if command := words[0]; !seen[command] {
+			seen[command] = true
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// commandCounts ranks the commands shell snippets start with by how many
+// snippets use them, most first, then by name.
+func commandCounts(snippets []snippet) []commandCount {
+	counts := map[string]int{}
+	for _, s := range snippets {
+		if !shellLanguages[strings.ToLower(strings.TrimSpace(s.Language))] {
+			continue
+		}
+		for _, command := range snippetCommands(s.Code) {
+			counts[command]++
+		}
+	}
+	ranked := make([]commandCount, 0, len(counts))
+	for command, n := range counts {
+		ranked = append(ranked, commandCount{command, n})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].snippets != ranked[j].snippets {
+			return ranked[i].snippets > ranked[j].snippets
+		}
+		return ranked[i].command < ranked[j].command
+	})
+	return ranked
+}
+
+// insightsView draws the Insights screen: the commands shell snippets are
+// kept for, ranked.
+func (m model) insightsView() string {
+	var s strings.Builder
+	s.WriteString(m.renderTitle("Insights"))
+	s.WriteString("\n\n")
+	ranked := commandCounts(m.snippets)
+	if len(ranked) == 0 {
+		s.WriteString(itemStyle.Render("No shell snippets yet, so no commands to count.") + "\n")
+		s.WriteString(m.keyHints())
+		return s.String()
+	}
+	s.WriteString(itemStyle.Render(fmt.Sprintf("Most common commands in shell snippets (%d different):", len(ranked))) + "\n\n")
+	shown := ranked[:min(len(ranked), insightsLimit)]
+	width := 0
+	for _, c := range shown {
+		width = max(width, len(c.command))
+	}
+	for i, c := range shown {
+		s.WriteString(itemStyle.Render(fmt.Sprintf("%2d. %-*s  %d", i+1, width, c.command, c.snippets)) + "\n")
+	}
+	if rest := len(ranked) - len(shown); rest > 0 {
+		s.WriteString(placeholderStyle.PaddingLeft(4).Render(fmt.Sprintf("and %d more", rest)) + "\n")
+	}
+	s.WriteString(m.keyHints())
+	return s.String()
+}
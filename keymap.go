@@ -0,0 +1,338 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// keyMap is every key binding in one place. Screens match keys against it
+// and build their footer hints from it, so the hints can't drift from what
+// the keys actually do. Bindings that share a key on different screens get
+// their own entry so each can describe itself.
+type keyMap struct {
+	Up, Down      key.Binding
+	Open          key.Binding
+	Back          key.Binding
+	Quit          key.Binding
+	More          key.Binding
+	Submit        key.Binding
+	Complete      key.Binding
+	PickLanguage  key.Binding
+	Save          key.Binding
+	Pin           key.Binding
+	CopyID        key.Binding
+	Sensitive     key.Binding
+	Reveal        key.Binding
+	Compare       key.Binding
+	CopyLanguage  key.Binding
+	DateFilter    key.Binding
+	Density       key.Binding
+	Copy          key.Binding
+	CopySnippet   key.Binding
+	Details       key.Binding
+	SelectMode    key.Binding
+	SelectUp      key.Binding
+	SelectDown    key.Binding
+	Delete        key.Binding
+	Rename        key.Binding
+	Merge         key.Binding
+	Check         key.Binding
+	DeleteTag     key.Binding
+	NewCollection key.Binding
+	Yes, No       key.Binding
+	Reload        key.Binding
+	Overwrite     key.Binding
+	MergeBoth     key.Binding
+	RemoveTag     key.Binding
+	Palette       key.Binding
+	AddNote       key.Binding
+	Search        key.Binding
+	NextMatch     key.Binding
+	PrevMatch     key.Binding
+	ClearFilters  key.Binding
+	TagSnippet    key.Binding
+	Pager         key.Binding
+	Sort          key.Binding
+	Colors        key.Binding
+	Whitespace    key.Binding
+	LineNumbers   key.Binding
+	Exclude       key.Binding
+	Archive       key.Binding
+	ShowArchived  key.Binding
+	Incomplete    key.Binding
+	RawMarkdown   key.Binding
+	Theme         key.Binding
+	CopyHeader    key.Binding
+	NextBlock     key.Binding
+	AddBlock      key.Binding
+	RemoveBlock   key.Binding
+	Wrap          key.Binding
+	ScrollLeft    key.Binding
+	ScrollRight   key.Binding
+	Rate          key.Binding
+	SuggestUp     key.Binding
+	SuggestDown   key.Binding
+	Sample        key.Binding
+	AnyKey        key.Binding
+	ClosePager    key.Binding
+	OpenSource    key.Binding
+	EditSource    key.Binding
+	Run           key.Binding
+}
+
+var keys = keyMap{
+	Up:            key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+	Down:          key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+	Open:          key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open")),
+	Back:          key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back")),
+	Quit:          key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit")),
+	More:          key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "more keys")),
+	Submit:        key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "save")),
+	Complete:      key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "complete")),
+	PickLanguage:  key.NewBinding(key.WithKeys("ctrl+l"), key.WithHelp("ctrl+l", "pick from list")),
+	Save:          key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "save")),
+	Pin:           key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "pin")),
+	CopyID:        key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "copy ID")),
+	Sensitive:     key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "mark sensitive")),
+	Reveal:        key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reveal")),
+	Compare:       key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "compare with clipboard")),
+	CopyLanguage:  key.NewBinding(key.WithKeys("L"), key.WithHelp("L", "copy all of this language")),
+	DateFilter:    key.NewBinding(key.WithKeys("D"), key.WithHelp("D", "filter by date")),
+	Density:       key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "density")),
+	Copy:          key.NewBinding(key.WithKeys("y", "enter"), key.WithHelp("y", "copy")),
+	CopySnippet:   key.NewBinding(key.WithKeys("enter", "y"), key.WithHelp("enter", "copy")),
+	Details:       key.NewBinding(key.WithKeys("right", "v"), key.WithHelp("→/v", "details")),
+	SelectMode:    key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "select lines")),
+	SelectUp:      key.NewBinding(key.WithKeys("shift+up"), key.WithHelp("shift+↑/↓", "extend selection")),
+	SelectDown:    key.NewBinding(key.WithKeys("shift+down")),
+	Delete:        key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "delete")),
+	Rename:        key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "rename")),
+	Merge:         key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "merge")),
+	Check:         key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "check")),
+	DeleteTag:     key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "delete")),
+	NewCollection: key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "new collection")),
+	Yes:           key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "yes")),
+	No:            key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "no")),
+	Reload:        key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "reload and discard yours")),
+	Overwrite:     key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "overwrite with yours")),
+	MergeBoth:     key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "merge both")),
+	RemoveTag:     key.NewBinding(key.WithKeys("backspace"), key.WithHelp("backspace", "remove last tag")),
+	Palette:       key.NewBinding(key.WithKeys("ctrl+k"), key.WithHelp("ctrl+k", "commands")),
+	AddNote:       key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "add note")),
+	Search:        key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search")),
+	NextMatch:     key.NewBinding(key.WithKeys("n"), key.WithHelp("n/N", "next/previous match")),
+	PrevMatch:     key.NewBinding(key.WithKeys("N"), key.WithHelp("N", "previous match")),
+	TagSnippet:    key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "add or remove a tag")),
+	Pager:         key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "open in pager")),
+	Sort:          key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "sort")),
+	Whitespace:    key.NewBinding(key.WithKeys("w"), key.WithHelp("w", "show whitespace")),
+	Archive:       key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "archive or restore")),
+	ShowArchived:  key.NewBinding(key.WithKeys("A"), key.WithHelp("A", "show archived")),
+	Incomplete:    key.NewBinding(key.WithKeys("I"), key.WithHelp("I", "only incomplete")),
+	CopyHeader:    key.NewBinding(key.WithKeys("Y"), key.WithHelp("Y", "copy with a name comment")),
+	NextBlock:     key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next language")),
+	AddBlock:      key.NewBinding(key.WithKeys("B"), key.WithHelp("B", "add a language")),
+	RemoveBlock:   key.NewBinding(key.WithKeys("X"), key.WithHelp("X", "remove this language")),
+	Wrap:          key.NewBinding(key.WithKeys("W"), key.WithHelp("W", "wrap lines")),
+	ScrollLeft:    key.NewBinding(key.WithKeys("<"), key.WithHelp("</>", "scroll sideways")),
+	ScrollRight:   key.NewBinding(key.WithKeys(">")),
+	Rate:          key.NewBinding(key.WithKeys("0", "1", "2", "3", "4", "5"), key.WithHelp("0-5", "rate")),
+	SuggestUp:     key.NewBinding(key.WithKeys("up"), key.WithHelp("↑/↓", "choose a suggestion")),
+	SuggestDown:   key.NewBinding(key.WithKeys("down")),
+	Sample:        key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "add a sample snippet")),
+	AnyKey:        key.NewBinding(key.WithHelp("any other key", "start")),
+	ClosePager:    key.NewBinding(key.WithKeys("esc", "q"), key.WithHelp("esc/q", "close")),
+	OpenSource:    key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "open source")),
+	EditSource:    key.NewBinding(key.WithKeys("U"), key.WithHelp("U", "set source")),
+	Run:           key.NewBinding(key.WithKeys("R"), key.WithHelp("R", "copy and run")),
+	Theme:         key.NewBinding(key.WithKeys("T"), key.WithHelp("T", "theme")),
+	RawMarkdown:   key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "show raw")),
+	Exclude:       key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "exclude")),
+	LineNumbers:   key.NewBinding(key.WithKeys("l"), key.WithHelp("l", "line numbers")),
+	Colors:        key.NewBinding(key.WithKeys("C"), key.WithHelp("C", "color by language")),
+	ClearFilters:  key.NewBinding(key.WithKeys("ctrl+l"), key.WithHelp("ctrl+l", "clear filters")),
+}
+
+// withHelp returns a copy of b described differently, for screens where
+// the same key means something more specific.
+func withHelp(b key.Binding, desc string) key.Binding {
+	b.SetHelp(b.Help().Key, desc)
+	return b
+}
+
+// screenKeys returns the bindings worth showing on the current screen: a
+// few of the most used ones, and the rest that '?' reveals.
+func (m model) screenKeys() (short, rest []key.Binding) {
+	switch m.state {
+	case "welcome":
+		// AnyKey has no keys of its own, it only describes the rest
+		short = []key.Binding{keys.Sample, keys.AnyKey}
+	case "view":
+		if m.taggingID != 0 {
+			return m.withSuggestKeys(withHelp(keys.Submit, "toggle tag"), keys.Complete, withHelp(keys.Back, "cancel")), nil
+		}
+		if m.searching {
+			return []key.Binding{withHelp(keys.Submit, "keep results"), withHelp(keys.Back, "clear search")}, nil
+		}
+		enter := keys.CopySnippet
+		if m.pick {
+			enter = withHelp(enter, "copy and exit")
+		}
+		back := keys.Back
+		if m.filtered() {
+			back = withHelp(back, "clear filters")
+		}
+		short = []key.Binding{keys.Up, keys.Down, enter, keys.Details, keys.Search, back}
+		if m.query != "" {
+			short = append(short, keys.NextMatch)
+		}
+		rest = []key.Binding{keys.CopyHeader, keys.Run, keys.TagSnippet, keys.Pager, keys.Pin, keys.Archive, keys.ShowArchived, withHelp(keys.Incomplete, "only incomplete ("+strconv.Itoa(countIncomplete(m.snippets))+")"), keys.CopyID, keys.Compare, keys.CopyLanguage, keys.DateFilter,
+			withHelp(keys.Density, "density ("+m.density.String()+")"), withHelp(keys.Sort, "sort ("+m.cfg.SortMode+")"), withHelp(keys.Theme, "theme ("+m.cfg.Theme+")"), keys.Colors, keys.Whitespace, m.wrapKey(), keys.LineNumbers, keys.Sensitive, keys.Reveal, keys.Palette, keys.Quit}
+		if !m.wrap {
+			rest = append(rest, keys.ScrollLeft)
+		}
+	case "detail":
+		if m.selectAnchor >= 0 {
+			return []key.Binding{keys.Up, keys.Down, withHelp(keys.Copy, "copy selected lines"), withHelp(keys.Back, "clear selection")}, nil
+		}
+		if m.finding {
+			return []key.Binding{withHelp(keys.Submit, "done"), withHelp(keys.Back, "clear find")}, nil
+		}
+		short = []key.Binding{keys.Copy, keys.SelectMode, keys.SelectUp, withHelp(keys.Search, "find"), keys.Back}
+		if m.findQuery != "" {
+			short = append([]key.Binding{keys.NextMatch, withHelp(keys.Back, "clear find")}, short[:4]...)
+		}
+		rest = []key.Binding{keys.CopyHeader, keys.Run, keys.Pager, keys.Whitespace, m.wrapKey(), keys.LineNumbers, keys.AddNote, keys.Rate, keys.EditSource, keys.CopyID, keys.AddBlock, keys.Palette, keys.Quit}
+		if i := m.findSnippet(m.detailID); i >= 0 && isURL(m.snippets[i].Source) {
+			rest = append([]key.Binding{keys.OpenSource}, rest...)
+		}
+		if !m.wrap {
+			rest = append(rest, keys.ScrollLeft)
+		}
+		if i := m.findSnippet(m.detailID); i >= 0 && len(m.snippets[i].Blocks) > 0 {
+			short = append([]key.Binding{keys.NextBlock}, short...)
+			if m.block > 0 {
+				rest = append([]key.Binding{keys.RemoveBlock}, rest...)
+			}
+		}
+		if i := m.findSnippet(m.detailID); i >= 0 && m.snippets[i].Sensitive {
+			rest = append([]key.Binding{withHelp(keys.Reveal, "reveal or hide")}, rest...)
+		}
+		if i := m.findSnippet(m.detailID); i >= 0 && isMarkdown(m.snippets[i]) {
+			raw := keys.RawMarkdown
+			if m.rawMarkdown {
+				raw = withHelp(raw, "render markdown")
+			}
+			short = append([]key.Binding{raw}, short...)
+		}
+	case "delete":
+		if m.merging {
+			return []key.Binding{keys.Up, keys.Down, withHelp(keys.Merge, "merge in (again on the same one to cancel)")}, nil
+		}
+		short = []key.Binding{keys.Up, keys.Down, keys.Delete, withHelp(keys.Back, "cancel")}
+		rest = []key.Binding{keys.Rename, keys.Pin, keys.Archive, keys.CopyID, keys.Merge, keys.Palette, keys.Quit}
+	case "tags":
+		short = []key.Binding{keys.Up, keys.Down, keys.Check, keys.Exclude, withHelp(keys.Open, "view matching")}
+		rest = []key.Binding{keys.Rename, keys.DeleteTag, withHelp(keys.Back, "cancel"), keys.Palette, keys.Quit}
+	case "languages":
+		short = []key.Binding{keys.Up, keys.Down, withHelp(keys.Open, "view matching"), keys.Back, keys.Quit}
+	case "collections":
+		short = []key.Binding{keys.Up, keys.Down, keys.Open, keys.NewCollection, withHelp(keys.Back, "cancel")}
+	case "conflict":
+		short = []key.Binding{keys.Reload, keys.Overwrite, keys.MergeBoth, withHelp(keys.Back, "decide later")}
+	case "pager":
+		vk := m.viewport.KeyMap
+		short = []key.Binding{vk.Up, vk.Down, vk.PageUp, vk.PageDown, keys.ClosePager}
+	case "stale":
+		short = []key.Binding{keys.Up, keys.Down, keys.Delete, withHelp(keys.Archive, "archive"), keys.Back}
+	case "diff", "info", "insights":
+		short = []key.Binding{keys.Back, keys.Quit}
+	case "rename":
+		short = []key.Binding{keys.Submit, withHelp(keys.Back, "cancel")}
+	case "fill":
+		short = []key.Binding{withHelp(keys.Submit, "next (copies after the last)"), withHelp(keys.Back, "cancel")}
+	case "note":
+		short = []key.Binding{withHelp(keys.Submit, "add"), withHelp(keys.Back, "cancel")}
+	case "source":
+		short = []key.Binding{keys.Submit, withHelp(keys.Back, "cancel")}
+	case "block":
+		if m.input.Focused() {
+			short = []key.Binding{withHelp(keys.Submit, "next"), withHelp(keys.Back, "cancel")}
+		} else {
+			short = []key.Binding{keys.Save, withHelp(keys.Back, "cancel")}
+		}
+	case "tagedit":
+		short = []key.Binding{withHelp(keys.Submit, "continue"), withHelp(keys.Back, "cancel")}
+	case "newcollection":
+		short = []key.Binding{withHelp(keys.Submit, "create and open"), withHelp(keys.Back, "cancel")}
+	case "datefilter":
+		short = []key.Binding{withHelp(keys.Submit, "apply (empty clears)"), withHelp(keys.Back, "cancel")}
+	case "palette":
+		short = []key.Binding{withHelp(keys.Submit, "run"), withHelp(keys.Back, "cancel")}
+	case "add":
+		switch m.currentField {
+		case fieldCode:
+			short = []key.Binding{keys.Save, withHelp(keys.Back, "cancel")}
+		case fieldSource:
+			short = []key.Binding{withHelp(keys.Submit, "next (empty skips)"), withHelp(keys.Back, "cancel")}
+		case fieldTags:
+			short = m.withSuggestKeys(withHelp(keys.Submit, "add tag (empty continues)"), keys.Complete, keys.RemoveTag, withHelp(keys.Back, "cancel"))
+		case fieldLanguage:
+			if m.pickingLang {
+				short = []key.Binding{keys.Up, keys.Down, withHelp(keys.Submit, "choose"), withHelp(keys.Search, "filter"), withHelp(keys.Back, "type instead")}
+				break
+			}
+			short = m.withSuggestKeys(withHelp(keys.Submit, "next"), keys.Complete, keys.PickLanguage, withHelp(keys.Back, "cancel"))
+		default:
+			short = []key.Binding{withHelp(keys.Submit, "next"), withHelp(keys.Back, "cancel")}
+		}
+	}
+	return short, rest
+}
+
+// withSuggestKeys returns bindings, led by the keys that move through
+// suggestions when there are any to move through. Only the arrows do,
+// since letters go into the input above them.
+func (m model) withSuggestKeys(bindings ...key.Binding) []key.Binding {
+	if len(m.suggestions()) > 1 {
+		return append([]key.Binding{keys.SuggestUp}, bindings...)
+	}
+	return bindings
+}
+
+// keyHints renders the footer line for the current screen from
+// screenKeys, with a '?' entry when there is more to show. A pending
+// confirmation takes its place, since no other keys work meanwhile.
+func (m model) keyHints() string {
+	if m.confirm != nil {
+		return m.confirmView()
+	}
+	short, rest := m.screenKeys()
+	bindings := short
+	if len(rest) > 0 {
+		if m.allKeys {
+			bindings = append(append([]key.Binding(nil), short...), rest...)
+			bindings = append(bindings, withHelp(keys.More, "fewer keys"))
+		} else {
+			bindings = append(append([]key.Binding(nil), short...), keys.More)
+		}
+	}
+
+	return quitTextStyle.Render(wrapHints(hintParts(bindings), m.lineWidth()))
+}
+
+// joinHints lists the help of bindings on one line.
+func joinHints(bindings []key.Binding) string {
+	return wrapHints(hintParts(bindings), 0)
+}
+
+func hintParts(bindings []key.Binding) []string {
+	var parts []string
+	for _, b := range bindings {
+		if h := b.Help(); h.Key != "" {
+			parts = append(parts, h.Key+" "+h.Desc)
+		}
+	}
+	return parts
+}
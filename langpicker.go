@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openLanguagePicker swaps the Language input for a list of every known
+// language, so one can be chosen rather than typed. Whatever was typed
+// already is selected if it is in the list.
+func (m model) openLanguagePicker() model {
+	langs := languageCandidates(m.snippets)
+	sort.Slice(langs, func(i, j int) bool {
+		return strings.ToLower(langs[i]) < strings.ToLower(langs[j])
+	})
+	items := make([]list.Item, len(langs))
+	selected := 0
+	for i, lang := range langs {
+		items[i] = item(lang)
+		if strings.EqualFold(lang, strings.TrimSpace(m.input.Value())) {
+			selected = i
+		}
+	}
+
+	delegate := list.NewDefaultDelegate()
+	delegate.ShowDescription = false
+	delegate.SetSpacing(0)
+	width, height := m.languagePickerSize()
+	l := list.New(items, delegate, width, height)
+	l.Title = "Choose a language"
+	l.SetShowStatusBar(false)
+	l.SetShowHelp(false)
+	// The picker is part of the add flow, so q is just a letter to filter
+	// by and quitting goes through quit() like everywhere else
+	l.KeyMap.Quit.SetEnabled(false)
+	l.KeyMap.ForceQuit.SetEnabled(false)
+	l.Select(selected)
+	m.langList = l
+	m.pickingLang = true
+	return m
+}
+
+// languagePickerSize leaves room under the picker for the key hints.
+func (m model) languagePickerSize() (int, int) {
+	if m.width == 0 {
+		// No size from the terminal yet
+		return 40, 14
+	}
+	return m.width, max(m.height-8, 5)
+}
+
+// updateLanguagePicker handles a key while the picker is open. Enter
+// takes the selected language and moves on to the tags, as if it had
+// been typed; Esc goes back to typing. While a filter is being typed the
+// list gets both keys so they apply to the filter.
+func (m model) updateLanguagePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyCtrlC {
+		return m.quit()
+	}
+	if m.langList.FilterState() != list.Filtering {
+		switch {
+		case key.Matches(msg, keys.Submit):
+			if lang, ok := m.langList.SelectedItem().(item); ok {
+				m.pickingLang = false
+				m.newSnippet.Language = string(lang)
+				m.input.SetValue("")
+				m.input.Placeholder = "Tag"
+				m.currentField++
+			}
+			return m, nil
+		case key.Matches(msg, keys.Back) && m.langList.FilterState() == list.Unfiltered:
+			m.pickingLang = false
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.langList, cmd = m.langList.Update(msg)
+	return m, cmd
+}
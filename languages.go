@@ -0,0 +1,291 @@
+package main
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sahilm/fuzzy"
+)
+
+// maxSuggestions caps how many language completions are listed under the
+// Language input.
+const maxSuggestions = 5
+
+// knownLanguages seeds the Language autocomplete before the user has any
+// snippets of their own.
+var knownLanguages = []string{
+	"bash", "c", "cpp", "csharp", "css", "dockerfile", "elixir", "go",
+	"graphql", "haskell", "html", "java", "javascript", "json", "kotlin",
+	"lua", "makefile", "markdown", "perl", "php", "powershell", "python",
+	"ruby", "rust", "scala", "sh", "sql", "swift", "toml", "typescript",
+	"yaml", "zsh",
+}
+
+// languageCandidates merges the built in languages with every language
+// already used in snippets, without duplicates.
+func languageCandidates(snippets []snippet) []string {
+	seen := map[string]bool{}
+	var candidates []string
+	add := func(lang string) {
+		key := strings.ToLower(lang)
+		if lang == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		candidates = append(candidates, lang)
+	}
+	for _, s := range snippets {
+		add(s.Language)
+	}
+	for _, lang := range knownLanguages {
+		add(lang)
+	}
+	return candidates
+}
+
+// suggestLanguages fuzzy matches query against the known languages, best
+// match first.
+func suggestLanguages(query string, snippets []snippet) []string {
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+
+	var suggestions []string
+	for _, match := range fuzzy.Find(query, languageCandidates(snippets)) {
+		if strings.EqualFold(match.Str, query) {
+			continue
+		}
+		suggestions = append(suggestions, match.Str)
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+	}
+	return suggestions
+}
+
+// commentSyntax maps a language to how it opens and closes a line comment.
+var commentSyntax = map[string][2]string{
+	"bash":       {"#", ""},
+	"dockerfile": {"#", ""},
+	"elixir":     {"#", ""},
+	"graphql":    {"#", ""},
+	"makefile":   {"#", ""},
+	"perl":       {"#", ""},
+	"powershell": {"#", ""},
+	"python":     {"#", ""},
+	"ruby":       {"#", ""},
+	"sh":         {"#", ""},
+	"toml":       {"#", ""},
+	"yaml":       {"#", ""},
+	"zsh":        {"#", ""},
+	"c":          {"//", ""},
+	"cpp":        {"//", ""},
+	"csharp":     {"//", ""},
+	"go":         {"//", ""},
+	"java":       {"//", ""},
+	"javascript": {"//", ""},
+	"kotlin":     {"//", ""},
+	"php":        {"//", ""},
+	"rust":       {"//", ""},
+	"scala":      {"//", ""},
+	"swift":      {"//", ""},
+	"typescript": {"//", ""},
+	"haskell":    {"--", ""},
+	"lua":        {"--", ""},
+	"sql":        {"--", ""},
+	"css":        {"/*", "*/"},
+	"html":       {"<!--", "-->"},
+	"markdown":   {"<!--", "-->"},
+}
+
+// commentLine renders text as a single line comment in lang, falling back
+// to '#' for languages we don't know.
+func commentLine(lang, text string) string {
+	syntax, ok := commentSyntax[strings.ToLower(lang)]
+	if !ok {
+		syntax = [2]string{"#", ""}
+	}
+	if syntax[1] == "" {
+		return syntax[0] + " " + text
+	}
+	return syntax[0] + " " + text + " " + syntax[1]
+}
+
+// defaultExtensions maps a language to the file extension its snippets are
+// written with. The extensions setting in config.json is merged over it.
+var defaultExtensions = map[string]string{
+	"bash":       ".sh",
+	"c":          ".c",
+	"cpp":        ".cpp",
+	"csharp":     ".cs",
+	"css":        ".css",
+	"dockerfile": ".dockerfile",
+	"elixir":     ".ex",
+	"go":         ".go",
+	"graphql":    ".graphql",
+	"haskell":    ".hs",
+	"html":       ".html",
+	"java":       ".java",
+	"javascript": ".js",
+	"json":       ".json",
+	"kotlin":     ".kt",
+	"lua":        ".lua",
+	"makefile":   ".mk",
+	"markdown":   ".md",
+	"perl":       ".pl",
+	"php":        ".php",
+	"powershell": ".ps1",
+	"python":     ".py",
+	"ruby":       ".rb",
+	"rust":       ".rs",
+	"scala":      ".scala",
+	"sh":         ".sh",
+	"shell":      ".sh",
+	"sql":        ".sql",
+	"swift":      ".swift",
+	"toml":       ".toml",
+	"typescript": ".ts",
+	"yaml":       ".yaml",
+	"zsh":        ".zsh",
+}
+
+// extensionFor returns the file extension for lang, preferring overrides
+// over the built in defaults and falling back to .txt.
+func extensionFor(lang string, overrides map[string]string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if ext, ok := overrides[lang]; ok {
+		return ext
+	}
+	if ext, ok := defaultExtensions[lang]; ok {
+		return ext
+	}
+	return ".txt"
+}
+
+// extraExtensions are extensions recognized on import that files are
+// never written with, on top of the reverse of defaultExtensions.
+var extraExtensions = map[string]string{
+	".bash": "bash",
+	".cc":   "cpp",
+	".htm":  "html",
+	".jsx":  "javascript",
+	".mjs":  "javascript",
+	".tsx":  "typescript",
+	".yml":  "yaml",
+}
+
+// languageFor returns the language of a file with extension ext, the
+// reverse of extensionFor: overrides first, then the built in
+// extensions, or "" if ext isn't known. When several languages share an
+// extension the one named after it wins, so .sh is "sh" rather than
+// "bash", and otherwise the first alphabetically.
+func languageFor(ext string, overrides map[string]string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext == "" {
+		return ""
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if lang := reverseLookup(ext, overrides); lang != "" {
+		return lang
+	}
+	if lang := reverseLookup(ext, defaultExtensions); lang != "" {
+		return lang
+	}
+	return extraExtensions[ext]
+}
+
+func reverseLookup(ext string, exts map[string]string) string {
+	var best string
+	for lang, e := range exts {
+		if e != ext {
+			continue
+		}
+		if lang == strings.TrimPrefix(ext, ".") {
+			return lang
+		}
+		if best == "" || lang < best {
+			best = lang
+		}
+	}
+	return best
+}
+
+// languagePalette holds the colors snippet names are drawn in by language.
+// Each is bright enough to read on a dark terminal and has a darker twin
+// for light ones.
+var languagePalette = []lipgloss.AdaptiveColor{
+	{Light: "#0B6E99", Dark: "#5FAFFF"},
+	{Light: "#1E7B34", Dark: "#5FD75F"},
+	{Light: "#9A5B00", Dark: "#FFAF5F"},
+	{Light: "#A4246B", Dark: "#FF87D7"},
+	{Light: "#00756E", Dark: "#5FD7D7"},
+	{Light: "#6B3FA0", Dark: "#AF87FF"},
+	{Light: "#8A6D00", Dark: "#D7D75F"},
+	{Light: "#B23A26", Dark: "#FF875F"},
+}
+
+// languageStyle returns the style a snippet name of lang is drawn in. The
+// color comes from a hash of the language, so it is the same every run
+// and for every spelling case, and a snippet with no language is plain.
+func languageStyle(lang string) lipgloss.Style {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" {
+		return lipgloss.NewStyle()
+	}
+	h := fnv.New32a()
+	h.Write([]byte(lang))
+	return lipgloss.NewStyle().Foreground(languagePalette[h.Sum32()%uint32(len(languagePalette))])
+}
+
+// languageCount is one bar of the Languages screen.
+type languageCount struct {
+	Language string
+	Count    int
+}
+
+// countLanguages returns every distinct language in snippets, most used
+// first and alphabetical among equals. Languages differing only in case
+// are counted together under the first spelling seen. Snippets with no
+// language aren't counted.
+func countLanguages(snippets []snippet) []languageCount {
+	index := map[string]int{}
+	var counts []languageCount
+	for _, s := range snippets {
+		lang := strings.TrimSpace(s.Language)
+		if lang == "" {
+			continue
+		}
+		key := strings.ToLower(lang)
+		i, ok := index[key]
+		if !ok {
+			i = len(counts)
+			index[key] = i
+			counts = append(counts, languageCount{Language: lang})
+		}
+		counts[i].Count++
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return strings.ToLower(counts[i].Language) < strings.ToLower(counts[j].Language)
+	})
+	return counts
+}
+
+// maxBarWidth is how many cells the longest bar on the Languages screen
+// takes.
+const maxBarWidth = 30
+
+// languageBar draws count as a bar scaled against max.
+func languageBar(count, max int) string {
+	width := count * maxBarWidth / max
+	if width == 0 {
+		width = 1
+	}
+	return strings.Repeat("█", width)
+}
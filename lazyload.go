@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// codeLoadedMsg brings in the whole library once the TUI is up, for a
+// collection that was opened with only its metadata.
+type codeLoadedMsg struct {
+	backend  store.Store
+	snippets []snippet
+	err      error
+}
+
+// loadLibrary reads a collection for the TUI. Stores that can list
+// snippets without their code do that, so the first screen doesn't wait on
+// decoding every body; the returned command reads the code in the
+// background, and codeless holds the IDs still waiting on it. Other stores
+// are read whole.
+func loadLibrary(backend store.Store) (snippets []snippet, version store.Version, codeless map[int]bool, load tea.Cmd, err error) {
+	lazy, ok := backend.(store.Lazy)
+	if !ok {
+		snippets, version, err = backend.Load()
+		return snippets, version, nil, nil, err
+	}
+	snippets, version, err = lazy.LoadMeta()
+	if err != nil || len(snippets) == 0 {
+		return snippets, version, nil, nil, err
+	}
+	codeless = make(map[int]bool, len(snippets))
+	for _, s := range snippets {
+		codeless[s.ID] = true
+	}
+	return snippets, version, codeless, func() tea.Msg {
+		full, _, err := backend.Load()
+		return codeLoadedMsg{backend: backend, snippets: full, err: err}
+	}, nil
+}
+
+// fillCode fetches the code of the snippet with the given ID if it is still
+// waiting on the background load, for screens that need it straight away.
+func (m *model) fillCode(id int) {
+	if !m.codeless[id] {
+		return
+	}
+	full, err := getSnippet(m.backend, id)
+	if err != nil {
+		m.err = fmt.Errorf("couldn't load the code of snippet %d: %w", id, err)
+		return
+	}
+	if i := m.findSnippet(id); i >= 0 {
+		m.setCode(i, full)
+	}
+}
+
+// setCode copies the parts LoadMeta leaves out from full into snippet i.
+func (m *model) setCode(i int, full snippet) {
+	s := &m.snippets[i]
+	s.Code, s.Blocks, s.Corrupt = full.Code, full.Blocks, full.Corrupt
+	delete(m.codeless, s.ID)
+	m.render.forget(s.ID)
+}
+
+// codeLoaded fills in every snippet still waiting on its code, then makes
+// any save that was held back for it.
+func (m model) codeLoaded(msg codeLoadedMsg) (tea.Model, tea.Cmd) {
+	if msg.backend != m.backend {
+		// A collection switched away from before its code came in
+		return m, nil
+	}
+	if msg.err != nil {
+		m.codeErr = msg.err
+		m.err = fmt.Errorf("couldn't load snippet code: %w; changes can't be saved", msg.err)
+		if m.quitting {
+			// A quit was waiting on this load to save first
+			m.quitting = false
+			return m.saveAndQuit()
+		}
+		return m, nil
+	}
+	m.applyCode(msg.snippets)
+	switch {
+	case m.quitting:
+		return m, m.startSave()
+	case m.dirty && m.cfg.SaveMode != saveModeManual:
+		m.dirty = false
+		return m, m.persist()
+	}
+	return m, nil
+}
+
+// fillAllCode loads the code of every snippet still waiting on it now,
+// for things that look at the whole library's code, like searching it or
+// bundling it, and can't wait for the background load. It reports
+// whether every snippet has its code.
+func (m *model) fillAllCode() bool {
+	if len(m.codeless) == 0 {
+		return true
+	}
+	full, _, err := m.backend.Load()
+	if err != nil {
+		m.err = fmt.Errorf("couldn't load snippet code: %w", err)
+		return false
+	}
+	m.applyCode(full)
+	return true
+}
+
+// applyCode fills the snippets waiting on their code from full, a whole
+// read of the library.
+func (m *model) applyCode(full []snippet) {
+	for _, s := range full {
+		if m.codeless[s.ID] {
+			if i := m.findSnippet(s.ID); i >= 0 {
+				m.setCode(i, s)
+			}
+		}
+	}
+	// Snippets deleted from the file since the list was read have no code
+	// to come; they are dropped rather than saved empty
+	for id := range m.codeless {
+		m.snippets = removeSnippet(m.snippets, id)
+	}
+	m.codeless = nil
+	m.codeErr = nil
+	m.index.sync(m.snippets)
+	if err := damagedCodeError(m.snippets); err != nil {
+		m.err = err
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// startedModel opens a snippets file holding snippets the way the program
+// starts, before the background code load has come back.
+func startedModel(t *testing.T, snippets []snippet) model {
+	t.Helper()
+	inTempDir(t)
+	if err := store.Save(snippetsFile, snippets); err != nil {
+		t.Fatal(err)
+	}
+	m, err := initialModel(defaultCollection, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 40})
+	return next.(model)
+}
+
+func TestWholeLibraryReadersWaitForCode(t *testing.T) {
+	library := []snippet{
+		{ID: 1, UID: "a", Name: "deploy", Language: "sh", Code: "kubectl apply -f deploy.yaml"},
+		{ID: 2, UID: "b", Name: "logs", Language: "sh", Code: "kubectl logs -f web"},
+		{ID: 3, UID: "c", Name: "query", Language: "sql", Code: "select * from users"},
+	}
+	tests := []struct {
+		name  string
+		run   func(m model) model
+		check func(t *testing.T, m model)
+	}{
+		{
+			"insights",
+			func(m model) model {
+				next, _ := m.openMenuItem("Insights")
+				return next.(model)
+			},
+			func(t *testing.T, m model) {
+				if view := m.View(); !strings.Contains(view, "kubectl") {
+					t.Errorf("insights don't count kubectl:\n%s", view)
+				}
+			},
+		},
+		{
+			"search in code",
+			func(m model) model {
+				m.navigate("view")
+				m.search.SetValue("users")
+				next, _ := m.Update(searchTickMsg(m.searchSeq))
+				return next.(model)
+			},
+			func(t *testing.T, m model) {
+				if got := ids(m.visibleSnippets()); len(got) != 1 || got[0] != 3 {
+					t.Errorf("search for code found %v, want [3]", got)
+				}
+			},
+		},
+		{
+			"incomplete filter",
+			func(m model) model {
+				m.navigate("view")
+				return send(m, "I")
+			},
+			func(t *testing.T, m model) {
+				if got := m.visibleSnippets(); len(got) != 0 {
+					t.Errorf("snippets waiting on their code listed as incomplete: %v", ids(got))
+				}
+			},
+		},
+		{
+			"language bundle",
+			func(m model) model {
+				m.navigate("view")
+				m = send(m, "L")
+				// There may be no clipboard to copy to here; what matters
+				// is what was given to it
+				if m.err != nil && strings.HasPrefix(m.err.Error(), "copy failed") {
+					m.err = nil
+				}
+				return m
+			},
+			func(t *testing.T, m model) {
+				if len(m.codeless) > 0 {
+					t.Errorf("bundled before the code was in: %v still waiting", m.codeless)
+				}
+				if text, n := bundleLanguage(m.snippets, "sh"); n != 2 || !strings.Contains(text, "kubectl logs") {
+					t.Errorf("bundle of %d: %q", n, text)
+				}
+			},
+		},
+		{
+			"duplicate check",
+			func(m model) model {
+				m.navigate("add")
+				m.currentField = fieldCode
+				m.textarea.SetValue("kubectl logs -f web")
+				return send(m, "ctrl+s")
+			},
+			func(t *testing.T, m model) {
+				if m.confirm == nil || !strings.Contains(m.confirm.prompt, `"logs"`) {
+					t.Errorf("saving a copy of logs didn't ask first: %+v", m.confirm)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := startedModel(t, library)
+			if len(m.codeless) == 0 {
+				t.Fatal("the library was read whole at startup")
+			}
+			m = tt.run(m)
+			if m.err != nil {
+				t.Fatal(m.err)
+			}
+			tt.check(t, m)
+		})
+	}
+}
+
+func TestQuitAfterCodeFailsToLoad(t *testing.T) {
+	m := startedModel(t, []snippet{{ID: 1, UID: "a", Name: "deploy", Language: "sh", Code: "make deploy"}})
+	if cmd := m.togglePin(1); cmd != nil || !m.dirty {
+		t.Fatalf("pin before the code loaded wasn't held back: dirty %v", m.dirty)
+	}
+	next, cmd := m.quit()
+	m = next.(model)
+	if cmd != nil || !m.quitting {
+		t.Fatalf("quit didn't wait for the code: quitting %v", m.quitting)
+	}
+
+	next, cmd = m.Update(codeLoadedMsg{backend: m.backend, err: errors.New("disk went away")})
+	m = next.(model)
+	if m.quitting {
+		t.Error("still waiting to quit after the load failed")
+	}
+	if m.confirm != nil {
+		next, cmd = m.answerConfirm(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+		m = next.(model)
+	}
+	if cmd == nil {
+		t.Fatal("no way to quit once the code failed to load")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Errorf("answering yes didn't quit")
+	}
+	saved, err := store.Load(snippetsFile)
+	if err != nil || len(saved) != 1 || saved[0].Code != "make deploy" || saved[0].Pinned {
+		t.Errorf("snippets file changed: %+v, %v", saved, err)
+	}
+}
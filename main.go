@@ -1,54 +1,126 @@
 package main
 
 import (
-	"bufio"
-	"encoding/base64"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/adammpkins/snipsnap/store"
 )
 
 const snippetsFile = "snippets.txt"
 
+// debugLogFile is where key presses and state changes are logged.
+const debugLogFile = "debug.log"
+
+// maxCodeLines is how many lines the code textarea accepts.
+const maxCodeLines = 9999
+
+// longLineWarning is the line length past which the add screen warns that
+// editing will be slow. Such lines are still saved exactly as typed.
+const longLineWarning = 2000
+
+// maskedCode stands in for the code of sensitive snippets until revealed.
+const maskedCode = "•••••••• (sensitive, press 'r' to reveal)"
+
+// saveDebounce is how long a burst of changes has to go quiet before the
+// debounced save mode writes them out.
+const saveDebounce = 500 * time.Millisecond
+
+// searchDebounce is how long typing in the search box has to pause before
+// the view is filtered again, so big libraries aren't searched on every
+// key. Until then model.query, what the view is filtered by, keeps the
+// previous search.
+const searchDebounce = 80 * time.Millisecond
+
+// Smallest terminal we can draw the menu and the add textarea in without
+// the layout wrapping into garbage.
+const (
+	minWidth  = 50
+	minHeight = 18
+)
+
+// The styles every screen draws with. applyTheme sets them from the
+// current theme's colors.
 var (
-	titleStyle = lipgloss.NewStyle().
-			MarginLeft(2).
-			Foreground(lipgloss.Color("#FAFAFA")).
-			Background(lipgloss.Color("#7D56F4")).
-			Padding(0, 1)
+	titleStyle        lipgloss.Style
+	itemStyle         lipgloss.Style
+	selectedItemStyle lipgloss.Style
+	paginationStyle   lipgloss.Style
+	helpStyle         lipgloss.Style
+	quitTextStyle     lipgloss.Style
+	inputStyle        lipgloss.Style
+	placeholderStyle  lipgloss.Style
+	addedLineStyle    lipgloss.Style
+	removedLineStyle  lipgloss.Style
+	chipStyle         lipgloss.Style
+	footerStyle       lipgloss.Style
+	errorStyle        lipgloss.Style
+	confirmStyle      lipgloss.Style
+)
 
-	itemStyle = lipgloss.NewStyle().
-			PaddingLeft(4).
-			Foreground(lipgloss.Color("#FAFAFA"))
+// snippet is the TUI's name for the stored snippet type.
+type snippet = store.Snippet
+
+// Steps of the add flow, in the order they are prompted for.
+const (
+	fieldName = iota
+	fieldLanguage
+	fieldTags
+	fieldSource
+	fieldCode
+)
 
-	selectedItemStyle = itemStyle.
-				Foreground(lipgloss.Color("#7D56F4"))
+// saveTickMsg fires after saveDebounce. It carries the save sequence number
+// it was scheduled for so that only the last tick in a burst writes.
+type saveTickMsg int
 
-	paginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
-	helpStyle       = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
+// searchTickMsg fires after searchDebounce, carrying the search sequence
+// number it was scheduled for like saveTickMsg.
+type searchTickMsg int
 
-	quitTextStyle = lipgloss.NewStyle().Margin(1, 0, 2, 4)
+// savedMsg reports that a background save has finished. err is
+// store.ErrChanged when the write was refused because of another writer.
+type savedMsg struct {
+	version store.Version
+	err     error
+}
 
-	inputStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FAFAFA"))
+// density controls how much metadata the view prints for each snippet.
+type density int
 
-	placeholderStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#BDBDBD"))
+const (
+	densityNormal density = iota
+	densityVerbose
+	densityCompact
 )
 
-type snippet struct {
-	ID       int
-	Name     string
-	Language string
-	Code     string
+func (d density) String() string {
+	switch d {
+	case densityVerbose:
+		return "verbose"
+	case densityCompact:
+		return "compact"
+	default:
+		return "normal"
+	}
 }
 
 type item string
@@ -58,68 +130,208 @@ func (i item) Title() string       { return string(i) }
 func (i item) Description() string { return "" }
 
 type model struct {
-	snippets     []snippet
-	state        string
-	input        textinput.Model
-	textarea     textarea.Model
-	currentField int
-	newSnippet   snippet
-	selectedItem int
-	err          error
-	list         list.Model
-	width        int
-	height       int
-	logger       *log.Logger
-}
-
-func initialModel() (model, error) {
+	snippets      []snippet
+	state         string
+	navStack      []string
+	input         textinput.Model
+	textarea      textarea.Model
+	currentField  int
+	suggestion    int
+	newSnippet    snippet
+	selectedItem  int
+	renameID      int
+	merging       bool
+	mergeID       int
+	detailID      int
+	diff          []diffLine
+	lineCursor    int
+	selectAnchor  int
+	status        string
+	density       density
+	tagCursor     int
+	langCursor    int
+	staleCursor   int
+	langFilter    string
+	tagSelected   map[string]bool
+	tagExcluded   map[string]bool
+	tagFilter     []string
+	tagExclude    []string
+	dateFilter    *dateRange
+	incomplete    bool
+	revealed      bool
+	showArchived  bool
+	tagTarget     string
+	err           error
+	list          list.Model
+	width         int
+	height        int
+	tooSmall      bool
+	cfg           config
+	dirty         bool
+	saveSeq       int
+	saving        bool
+	quitting      bool
+	spinner       spinner.Model
+	diskVersion   store.Version
+	collection    string
+	backend       store.Store
+	collCursor    int
+	logger        *log.Logger
+	pick          bool
+	capture       bool
+	nextID        int
+	fill          *templateFill
+	allKeys       bool
+	paletteCursor int
+	confirm       *confirmation
+	search        textinput.Model
+	searching     bool
+	query         string
+	searchSeq     int
+	taggingID     int
+	whitespace    bool
+	index         *searchIndex
+	render        *renderCache
+	viewport      viewport.Model
+	pagerName     string
+	rawMarkdown   bool
+	find          textinput.Model
+	finding       bool
+	findQuery     string
+	block         int
+	wrap          bool
+	hscroll       int
+	readOnly      bool
+	langList      list.Model
+	pickingLang   bool
+	codeless      map[int]bool
+	codeLoad      tea.Cmd
+	codeErr       error
+}
+
+func initialModel(collection, storage string, readOnly bool) (model, error) {
 	items := []list.Item{
 		item("View Snippets"),
 		item("Add Snippet"),
+		item("Browse Tags"),
+		item("Browse Languages"),
 		item("Delete Snippet"),
+		item("Switch Collection"),
+		item("Library Info"),
+		item("Insights"),
+		item("Stale Snippets"),
 		item("Quit"),
 	}
 
-	l := list.New(items, list.NewDefaultDelegate(), 0, 0)
+	l := list.New(items, menuDelegate{list.NewDefaultDelegate(), readOnly}, 0, 0)
 	l.Title = "Snippet Manager"
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(false)
 	l.Styles.Title = titleStyle
 	l.Styles.PaginationStyle = paginationStyle
 	l.Styles.HelpStyle = helpStyle
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{keys.Palette}
+	}
 
 	ti := textinput.New()
 	ti.PlaceholderStyle = placeholderStyle
 	ti.TextStyle = inputStyle
 
+	si := textinput.New()
+	si.Prompt = "/"
+	si.Placeholder = "Search names, tags and code"
+	si.PlaceholderStyle = placeholderStyle
+	si.TextStyle = inputStyle
+
 	ta := textarea.New()
 	ta.Placeholder = "Enter snippet code"
 	ta.CharLimit = 0
+	// The default caps the code at 99 lines; keep room for long pastes
+	// and a gutter wide enough for their line numbers
+	ta.MaxHeight = maxCodeLines
 	ta.ShowLineNumbers = true
 	ta.Prompt = "|"
 	ta.SetWidth(40)
 	ta.SetHeight(10)
 
+	cfg, err := loadConfig()
+	if err != nil {
+		return model{}, err
+	}
+	if storage != "" {
+		cfg.Storage = storage
+	}
+
+	if collection == "" {
+		collection = defaultCollection
+	}
+	storePath, err := collectionPath(collection)
+	if err != nil {
+		return model{}, err
+	}
+
+	// Fail up front rather than at the first save if there is nowhere to
+	// keep the snippets or the log
+	for _, dir := range []string{filepath.Dir(storePath), filepath.Dir(debugLogFile)} {
+		if err := store.EnsureDir(dir); err != nil {
+			return model{}, err
+		}
+	}
+
 	// Set up logger
-	logFile, err := os.OpenFile("debug.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	logFile, err := os.OpenFile(debugLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return model{}, fmt.Errorf("failed to open log file: %v", err)
 	}
 
 	logger := log.New(logFile, "", log.LstdFlags)
 
-	return model{
-		snippets: loadSnippets(),
-		state:    "menu",
-		input:    ti,
-		textarea: ta,
-		list:     l,
-		logger:   logger,
-	}, nil
+	// With no snippets file or database yet this is the first run, so
+	// greet the user
+	state := "menu"
+	if !readOnly && collection == defaultCollection && !exists(snippetsFile) && !exists(databasePath(snippetsFile)) {
+		state = "welcome"
+	}
+
+	backend, err := openCollection(collection, cfg.Storage, readOnly)
+	if err != nil {
+		return model{}, err
+	}
+	snippets, version, codeless, codeLoad, err := loadLibrary(backend)
+	if err != nil {
+		return model{}, fmt.Errorf("failed to load %s: %v", backend.Location(), err)
+	}
+
+	t, _ := findTheme(cfg.Theme)
+	applyTheme(t)
+	m := model{
+		snippets:    snippets,
+		state:       state,
+		input:       ti,
+		search:      si,
+		index:       newSearchIndex(snippets),
+		render:      newRenderCache(),
+		textarea:    ta,
+		list:        l,
+		cfg:         cfg,
+		spinner:     spinner.New(spinner.WithSpinner(spinner.Dot)),
+		diskVersion: version,
+		nextID:      backend.NextID(),
+		err:         damagedCodeError(snippets),
+		collection:  collection,
+		backend:     backend,
+		logger:      logger,
+		readOnly:    readOnly,
+		codeless:    codeless,
+		codeLoad:    codeLoad,
+	}
+	m.restyle()
+	return m, nil
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	return m.codeLoad
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -127,108 +339,1038 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		m.tooSmall = m.width < minWidth || m.height < minHeight
 		m.list.SetSize(msg.Width, msg.Height)
+		if m.pickingLang {
+			m.langList.SetSize(m.languagePickerSize())
+		}
+		if m.state == "pager" {
+			m.viewport.Width, m.viewport.Height = m.pagerSize()
+		}
+		return m, nil
+
+	case list.FilterMatchesMsg:
+		// The language picker filters in the background
+		if m.pickingLang {
+			var cmd tea.Cmd
+			m.langList, cmd = m.langList.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+
+	case saveTickMsg:
+		if int(msg) == m.saveSeq {
+			return m, m.startSave()
+		}
+		return m, nil
+
+	case searchTickMsg:
+		if int(msg) == m.searchSeq {
+			m.query = m.search.Value()
+			m.selectedItem = 0
+			if m.query != "" {
+				// Search looks in code too
+				m.fillAllCode()
+			}
+		}
+		return m, nil
+
+	case savedMsg:
+		m.saving = false
+		if errors.Is(msg.err, store.ErrChanged) {
+			m.dirty = true
+			m.quitting = false
+			if m.state != "conflict" {
+				m.navigate("conflict")
+			}
+			return m, nil
+		}
+		if msg.err != nil {
+			m.dirty = true
+			m.quitting = false
+			m.err = fmt.Errorf("couldn't save: %w", msg.err)
+			return m, nil
+		}
+		m.diskVersion = msg.version
+		if m.dirty && (m.quitting || m.cfg.SaveMode == saveModeDebounce) {
+			// More changes came in while we were writing
+			return m, m.startSave()
+		}
+		if m.quitting {
+			return m, tea.Quit
+		}
+		m.err = nil
+		m.status = "Saved"
+		return m, nil
+
+	case pagerDoneMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("pager failed: %w", msg.err)
+		}
 		return m, nil
 
+	case codeLoadedMsg:
+		return m.codeLoaded(msg)
+
+	case runDoneMsg:
+		return m.showRunOutput(msg), nil
+
+	case spinner.TickMsg:
+		if !m.saving {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
 	case tea.KeyMsg:
 		// Add logging
 		m.logger.Printf("Key pressed: %s, Current state: %s\n", msg.String(), m.state)
 
+		// Any key leaves the welcome screen; 's' also adds a sample
+		if m.state == "welcome" {
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			if key.Matches(msg, keys.Sample) {
+				m.snippets = append(m.snippets, sampleSnippet(m.takeID()))
+				m.index.sync(m.snippets)
+			}
+			// Writing the file, even empty, marks the first run as done
+			m.diskVersion, _ = m.backend.Save(m.snippets, m.diskVersion)
+			m.state = "menu"
+			return m, nil
+		}
+
+		// A pending confirmation takes every key until it is answered
+		if m.confirm != nil {
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			return m.answerConfirm(msg)
+		}
+
+		// So does the language picker, which has its own keys
+		if m.pickingLang {
+			return m.updateLanguagePicker(msg)
+		}
+
 		// Handle Esc key globally
-		if msg.Type == tea.KeyEsc {
+		if key.Matches(msg, keys.Back) {
 			m.logger.Println("Esc key pressed. Handling...")
 			switch m.state {
 			case "menu":
 				// In menu, Esc does nothing
 				m.logger.Println("In menu, Esc does nothing")
+			case "view":
+				if m.taggingID != 0 {
+					return m.closeTagInput(), nil
+				}
+				// With a search or filter on, Esc shows everything again
+				// rather than leaving
+				if m.searching || m.filtered() {
+					return m.clearFilters(), nil
+				}
+				if m.pick {
+					return m.quit()
+				}
+				return m.back(), nil
+			case "detail":
+				// Esc first drops an active line selection, then a find
+				if m.selectAnchor >= 0 {
+					m.selectAnchor = -1
+					return m, nil
+				}
+				if m.finding || m.findQuery != "" {
+					m.finding = false
+					m.findQuery = ""
+					return m, nil
+				}
+				return m.back(), nil
+			case "diff":
+				m.diff = nil
+				return m.back(), nil
+			case "add":
+				if m.capture {
+					return m.quit()
+				}
+				return m.back(), nil
 			default:
-				// In other states, Esc should return to menu
-				m.logger.Println("Returning to menu due to Esc")
-				return m.resetState(), nil
+				// Everywhere else Esc goes back one screen, which is the
+				// menu at the top of a flow. A pending conflict keeps its
+				// changes marked unsaved.
+				m.logger.Println("Going back due to Esc")
+				return m.back(), nil
 			}
 		}
 
-		if msg.String() == "q" {
+		m.status = ""
+		m.err = nil
+
+		if m.readOnly && !m.editingText() && m.changesSnippets(msg) {
+			m.status = readOnlyStatus
+			return m, nil
+		}
+
+		if key.Matches(msg, keys.Save) && m.state != "add" && m.state != "block" {
+			return m, m.startSave()
+		}
+
+		if key.Matches(msg, keys.Palette) && !m.editingText() {
+			m.navigate("palette")
+			m.paletteCursor = 0
+			m.input.Placeholder = "Type a command"
+			m.input.SetValue("")
+			m.input.Focus()
+			return m, nil
+		}
+
+		// q closes the built in pager, as it does less
+		if m.state == "pager" && key.Matches(msg, keys.ClosePager) {
+			return m.back(), nil
+		}
+		if key.Matches(msg, keys.Quit) && !m.editingText() {
 			m.logger.Println("Quitting application due to 'q' key")
-			return m, tea.Quit
+			return m.quit()
+		}
+		if key.Matches(msg, keys.More) && !m.editingText() {
+			m.allKeys = !m.allKeys
+			return m, nil
 		}
 		switch m.state {
 		case "menu":
 			if msg.Type == tea.KeyCtrlC {
-				return m, tea.Quit
+				return m.quit()
 			}
 			if msg.Type == tea.KeyEnter {
-				i, ok := m.list.SelectedItem().(item)
-				if ok {
-					switch string(i) {
-					case "View Snippets":
-						m.state = "view"
-					case "Add Snippet":
-						m.state = "add"
-						m.currentField = 0
-						m.newSnippet = snippet{}
-						m.input.Placeholder = "Name"
+				if i, ok := m.list.SelectedItem().(item); ok {
+					return m.openMenuItem(string(i))
+				}
+			}
+		case "add":
+			if m.currentField == fieldLanguage && key.Matches(msg, keys.PickLanguage) {
+				return m.openLanguagePicker(), nil
+			}
+			if m.currentField == fieldLanguage || m.currentField == fieldTags {
+				suggestions := m.suggestions()
+				switch {
+				case key.Matches(msg, keys.SuggestUp):
+					if m.suggestion > 0 {
+						m.suggestion--
+					}
+					return m, nil
+				case key.Matches(msg, keys.SuggestDown):
+					if m.suggestion < len(suggestions)-1 {
+						m.suggestion++
+					}
+					return m, nil
+				case key.Matches(msg, keys.Complete):
+					if m.suggestion < len(suggestions) {
+						if m.currentField == fieldTags {
+							m.newSnippet.Tags = addTag(m.newSnippet.Tags, suggestions[m.suggestion])
+							m.input.SetValue("")
+						} else {
+							m.input.SetValue(suggestions[m.suggestion])
+							m.input.CursorEnd()
+						}
+					}
+					m.suggestion = 0
+					return m, nil
+				default:
+					m.suggestion = 0
+				}
+			}
+			if m.currentField == fieldTags {
+				switch {
+				case key.Matches(msg, keys.RemoveTag):
+					// Backspace on an empty input removes the last chip
+					if m.input.Value() == "" && len(m.newSnippet.Tags) > 0 {
+						m.newSnippet.Tags = m.newSnippet.Tags[:len(m.newSnippet.Tags)-1]
+						return m, nil
+					}
+				case key.Matches(msg, keys.Submit):
+					// Enter turns typed text into a chip; on an empty input
+					// it falls through and moves on to the code
+					if strings.TrimSpace(m.input.Value()) != "" {
+						m.newSnippet.Tags = addTag(m.newSnippet.Tags, m.input.Value())
 						m.input.SetValue("")
-						m.input.Focus()
-					case "Delete Snippet":
-						m.state = "delete"
-						m.selectedItem = 0
-					case "Quit":
-						return m, tea.Quit
+						return m, nil
 					}
 				}
 			}
-		case "add":
-			switch msg.Type {
-			case tea.KeyEnter:
-				if m.currentField < 2 {
+			switch {
+			case key.Matches(msg, keys.Submit):
+				if m.currentField < fieldCode {
 					switch m.currentField {
-					case 0:
+					case fieldName:
 						m.newSnippet.Name = m.input.Value()
-						m.input.SetValue("")
+						m.input.SetValue(m.cfg.DefaultLanguage)
 						m.input.Placeholder = "Language"
 						m.currentField++
-					case 1:
+					case fieldLanguage:
 						m.newSnippet.Language = m.input.Value()
 						m.input.SetValue("")
+						m.input.Placeholder = "Tag"
+						m.currentField++
+					case fieldTags:
+						m.input.SetValue("")
+						m.input.Placeholder = "Source URL or reference (optional)"
+						m.currentField++
+					case fieldSource:
+						m.newSnippet.Source = cleanSource(m.input.Value())
+						m.input.SetValue("")
 						m.textarea.Focus()
 						m.currentField++
 					}
+					return m, nil
 				}
 				// If we're in the textarea, let it handle the Enter key
-			case tea.KeyCtrlS:
-				if m.currentField == 2 {
-					// Submit the snippet
-					m.newSnippet.Code = m.textarea.Value()
-					m.newSnippet.ID = generateID(m.snippets)
-					m.snippets = append(m.snippets, m.newSnippet)
-					saveSnippets(m.snippets)
-					return m.resetState(), nil
+			case key.Matches(msg, keys.Save):
+				if m.currentField == fieldCode {
+					if !m.fillAllCode() {
+						return m, nil
+					}
+					if dup, ok := findDuplicate(m.snippets, m.textarea.Value()); ok {
+						return m.ask(confirmation{
+							prompt: fmt.Sprintf("This code matches %q. Save anyway?", dup.Name),
+							onYes: func(m model) (tea.Model, tea.Cmd) {
+								return m.addSnippet()
+							},
+						})
+					}
+					return m.addSnippet()
 				}
 			}
 		case "delete":
-			if msg.Type == tea.KeyEnter {
-				if m.selectedItem >= 0 && m.selectedItem < len(m.snippets) {
-					m.snippets = append(m.snippets[:m.selectedItem], m.snippets[m.selectedItem+1:]...)
-					saveSnippets(m.snippets)
+			ordered := orderForDisplay(m.snippets, m.cfg.SortMode)
+			var selected snippet
+			hasSelection := m.selectedItem >= 0 && m.selectedItem < len(ordered)
+			if hasSelection {
+				selected = ordered[m.selectedItem]
+			}
+			if key.Matches(msg, keys.Delete) {
+				if !hasSelection {
+					return m.resetState(), nil
 				}
-				m.state = "menu"
-				m.selectedItem = 0
-			} else if msg.String() == "up" && m.selectedItem > 0 {
+				return m.ask(confirmation{
+					prompt: fmt.Sprintf("Delete %q?", selected.Name),
+					onYes: func(m model) (tea.Model, tea.Cmd) {
+						m.snippets = removeSnippet(m.snippets, selected.ID)
+						cmd := m.persist()
+						return m.resetState(), cmd
+					},
+				})
+			} else if key.Matches(msg, keys.Rename) && hasSelection {
+				m.navigate("rename")
+				m.renameID = selected.ID
+				m.input.Placeholder = "Name"
+				m.input.SetValue(selected.Name)
+				m.input.CursorEnd()
+				m.input.Focus()
+				return m, nil
+			} else if key.Matches(msg, keys.Pin) && hasSelection {
+				cmd := m.togglePin(selected.ID)
+				// Keep the cursor on the snippet as it moves
+				m.selectedItem = indexOf(orderForDisplay(m.snippets, m.cfg.SortMode), selected.ID)
+				return m, cmd
+			} else if key.Matches(msg, keys.Archive) && hasSelection {
+				return m, m.toggleArchive(selected.ID)
+			} else if key.Matches(msg, keys.CopyID) && hasSelection {
+				m.copyField(fmt.Sprintf("ID %d", selected.ID), strconv.Itoa(selected.ID))
+				return m, nil
+			} else if key.Matches(msg, keys.Merge) && hasSelection {
+				// The first 'm' picks the snippet to keep, the second the
+				// one to fold into it
+				switch {
+				case !m.merging:
+					m.merging = true
+					m.mergeID = selected.ID
+				case selected.ID == m.mergeID:
+					m.merging = false
+				default:
+					i := m.findSnippet(m.mergeID)
+					if i < 0 {
+						m.merging = false
+						return m, nil
+					}
+					primary, secondary := m.snippets[i], selected
+					return m.ask(confirmation{
+						prompt: fmt.Sprintf("Merge %q into %q and delete %q?", secondary.Name, primary.Name, secondary.Name),
+						onYes: func(m model) (tea.Model, tea.Cmd) {
+							m.fillCode(primary.ID)
+							m.fillCode(secondary.ID)
+							m.snippets = mergeSnippets(m.snippets, primary.ID, secondary.ID)
+							m.merging = false
+							if i := indexOf(orderForDisplay(m.snippets, m.cfg.SortMode), primary.ID); i >= 0 {
+								m.selectedItem = i
+							}
+							return m, m.persist()
+						},
+						onNo: func(m model) model {
+							m.merging = false
+							return m
+						},
+					})
+				}
+				return m, nil
+			} else if key.Matches(msg, keys.Up) && m.selectedItem > 0 {
 				m.selectedItem--
-			} else if msg.String() == "down" && m.selectedItem < len(m.snippets)-1 {
+			} else if key.Matches(msg, keys.Down) && m.selectedItem < len(ordered)-1 {
 				m.selectedItem++
 			}
+		case "collections":
+			names := listCollections()
+			switch {
+			case key.Matches(msg, keys.Up):
+				if m.collCursor > 0 {
+					m.collCursor--
+				}
+			case key.Matches(msg, keys.Down):
+				if m.collCursor < len(names)-1 {
+					m.collCursor++
+				}
+			case key.Matches(msg, keys.NewCollection):
+				m.navigate("newcollection")
+				m.input.Placeholder = "Collection name"
+				m.input.SetValue("")
+				m.input.Focus()
+			case key.Matches(msg, keys.Open):
+				if m.collCursor < len(names) {
+					return m.switchCollection(names[m.collCursor])
+				}
+			}
+			return m, nil
+		case "newcollection":
+			if key.Matches(msg, keys.Submit) {
+				name := strings.TrimSpace(m.input.Value())
+				m.input.SetValue("")
+				m.input.Blur()
+				if name == "" || strings.ContainsAny(name, `/\.`) {
+					m = m.back()
+					m.err = errors.New("collection names can't be empty or contain / \\ or .")
+					return m, nil
+				}
+				return m.switchCollection(name)
+			}
+		case "conflict":
+			switch {
+			case key.Matches(msg, keys.Reload):
+				snippets, version, err := m.backend.Load()
+				if err != nil {
+					m.err = fmt.Errorf("couldn't reload snippets: %w", err)
+					return m, nil
+				}
+				m.snippets = snippets
+				m.codeless = nil
+				m.diskVersion = version
+				m.nextID = max(m.nextID, m.backend.NextID())
+				m.err = damagedCodeError(snippets)
+				m.dirty = false
+				return m.resetState(), nil
+			case key.Matches(msg, keys.Overwrite):
+				m.diskVersion = m.backend.Version()
+				m.dirty = true
+				return m.resetState(), m.startSave()
+			case key.Matches(msg, keys.MergeBoth):
+				disk, version, err := m.backend.Load()
+				if err != nil {
+					m.err = fmt.Errorf("couldn't reload snippets: %w", err)
+					return m, nil
+				}
+				m.nextID = max(m.nextID, m.backend.NextID())
+				m.snippets = unionSnippets(disk, m.snippets, m.takeID)
+				m.diskVersion = version
+				m.dirty = true
+				return m.resetState(), m.startSave()
+			}
+			return m, nil
+		case "fill":
+			if key.Matches(msg, keys.Submit) {
+				return m.nextPlaceholder()
+			}
+		case "source":
+			if key.Matches(msg, keys.Submit) {
+				var cmd tea.Cmd
+				if i := m.findSnippet(m.detailID); i >= 0 {
+					m.snippets[i].Source = cleanSource(m.input.Value())
+					cmd = m.persist()
+				}
+				return m.back(), cmd
+			}
+		case "note":
+			if key.Matches(msg, keys.Submit) {
+				var cmd tea.Cmd
+				if i := m.findSnippet(m.detailID); i >= 0 && strings.TrimSpace(m.input.Value()) != "" {
+					m.snippets[i].Notes = appendNote(m.snippets[i].Notes, m.input.Value(), time.Now())
+					// The damaged notes are kept as text above the new entry
+					m.snippets[i].NotesCorrupt = false
+					cmd = m.persist()
+				}
+				return m.back(), cmd
+			}
+		case "rename":
+			if key.Matches(msg, keys.Submit) {
+				if i := m.findSnippet(m.renameID); i >= 0 {
+					m.snippets[i].Name = m.input.Value()
+				}
+				cmd := m.persist()
+				return m.back(), cmd
+			}
+		case "pager":
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		case "stale":
+			stale := staleSnippets(m.snippets, m.cfg.StaleDays, time.Now())
+			switch {
+			case key.Matches(msg, keys.Up):
+				if m.staleCursor > 0 {
+					m.staleCursor--
+				}
+			case key.Matches(msg, keys.Down):
+				if m.staleCursor < len(stale)-1 {
+					m.staleCursor++
+				}
+			case key.Matches(msg, keys.Archive):
+				if m.staleCursor < len(stale) {
+					cmd := m.toggleArchive(stale[m.staleCursor].ID)
+					m.staleCursor = max(min(m.staleCursor, len(stale)-2), 0)
+					return m, cmd
+				}
+			case key.Matches(msg, keys.Delete):
+				if m.staleCursor < len(stale) {
+					selected := stale[m.staleCursor]
+					return m.ask(confirmation{
+						prompt: fmt.Sprintf("Delete %q?", selected.Name),
+						onYes: func(m model) (tea.Model, tea.Cmd) {
+							m.snippets = removeSnippet(m.snippets, selected.ID)
+							m.staleCursor = max(min(m.staleCursor, len(stale)-2), 0)
+							m.status = fmt.Sprintf("Deleted %s", selected.Name)
+							return m, m.persist()
+						},
+					})
+				}
+			}
+			return m, nil
+		case "languages":
+			langs := countLanguages(m.snippets)
+			switch {
+			case key.Matches(msg, keys.Up):
+				if m.langCursor > 0 {
+					m.langCursor--
+				}
+			case key.Matches(msg, keys.Down):
+				if m.langCursor < len(langs)-1 {
+					m.langCursor++
+				}
+			case key.Matches(msg, keys.Open):
+				if m.langCursor < len(langs) {
+					m.langFilter = langs[m.langCursor].Language
+					m.navigate("view")
+					m.selectedItem = 0
+				}
+			}
+			return m, nil
+		case "tags":
+			tags := countTags(m.snippets)
+			switch {
+			case key.Matches(msg, keys.Up):
+				if m.tagCursor > 0 {
+					m.tagCursor--
+				}
+			case key.Matches(msg, keys.Down):
+				if m.tagCursor < len(tags)-1 {
+					m.tagCursor++
+				}
+			case key.Matches(msg, keys.Check):
+				if m.tagCursor < len(tags) {
+					t := tags[m.tagCursor].Tag
+					m.tagSelected[t] = !m.tagSelected[t]
+					delete(m.tagExcluded, t)
+				}
+			case key.Matches(msg, keys.Exclude):
+				if m.tagCursor < len(tags) {
+					t := tags[m.tagCursor].Tag
+					m.tagExcluded[t] = !m.tagExcluded[t]
+					delete(m.tagSelected, t)
+				}
+			case key.Matches(msg, keys.Open):
+				// Filter on every checked tag and out every excluded one,
+				// or on just the highlighted one if nothing is marked
+				m.tagFilter, m.tagExclude = nil, nil
+				for _, t := range tags {
+					if m.tagSelected[t.Tag] {
+						m.tagFilter = append(m.tagFilter, t.Tag)
+					}
+					if m.tagExcluded[t.Tag] {
+						m.tagExclude = append(m.tagExclude, t.Tag)
+					}
+				}
+				if len(m.tagFilter) == 0 && len(m.tagExclude) == 0 && m.tagCursor < len(tags) {
+					m.tagFilter = []string{tags[m.tagCursor].Tag}
+				}
+				if len(m.tagFilter) > 0 || len(m.tagExclude) > 0 {
+					m.navigate("view")
+					m.selectedItem = 0
+				}
+				return m, nil
+			case key.Matches(msg, keys.Rename):
+				if m.tagCursor < len(tags) {
+					m.navigate("tagedit")
+					m.tagTarget = tags[m.tagCursor].Tag
+					m.input.Placeholder = "New tag name"
+					m.input.SetValue(m.tagTarget)
+					m.input.CursorEnd()
+					m.input.Focus()
+				}
+				return m, nil
+			case key.Matches(msg, keys.DeleteTag):
+				if m.tagCursor < len(tags) {
+					t := tags[m.tagCursor]
+					return m.ask(confirmation{
+						prompt: fmt.Sprintf("Remove tag %q from %d snippets?", t.Tag, t.Count),
+						onYes: func(m model) (tea.Model, tea.Cmd) {
+							n := removeTag(m.snippets, t.Tag)
+							m.status = fmt.Sprintf("Removed %q from %d snippets", t.Tag, n)
+							m.tagCursor = 0
+							m.tagSelected = map[string]bool{}
+							m.tagExcluded = map[string]bool{}
+							return m, m.persist()
+						},
+					})
+				}
+				return m, nil
+			}
+		case "palette":
+			actions := filterPalette(m.input.Value(), paletteActions())
+			switch {
+			case msg.Type == tea.KeyUp:
+				if m.paletteCursor > 0 {
+					m.paletteCursor--
+				}
+				return m, nil
+			case msg.Type == tea.KeyDown:
+				if m.paletteCursor < len(actions)-1 {
+					m.paletteCursor++
+				}
+				return m, nil
+			case key.Matches(msg, keys.Submit):
+				if m.paletteCursor >= len(actions) {
+					return m, nil
+				}
+				// Leave the palette first so the action's screen stacks on
+				// wherever it was opened from
+				action := actions[m.paletteCursor]
+				m = m.back()
+				return action.run(m)
+			default:
+				m.paletteCursor = 0
+			}
+		case "datefilter":
+			if key.Matches(msg, keys.Submit) {
+				// An empty range clears the filter
+				if strings.TrimSpace(m.input.Value()) == "" {
+					m.dateFilter = nil
+				} else {
+					r, err := parseDateRange(m.input.Value(), time.Now())
+					if err != nil {
+						m.err = err
+						return m, nil
+					}
+					m.dateFilter = &r
+				}
+				m.selectedItem = 0
+				return m.back(), nil
+			}
+		case "tagedit":
+			if key.Matches(msg, keys.Submit) {
+				from, to := m.tagTarget, store.NormalizeTag(m.input.Value())
+				// Ask from the tag list, so answering either way lands there
+				m = m.back()
+				if to == "" || to == from {
+					return m, nil
+				}
+				n := 0
+				for _, t := range countTags(m.snippets) {
+					if t.Tag == from {
+						n = t.Count
+					}
+				}
+				return m.ask(confirmation{
+					prompt: fmt.Sprintf("Rename tag %q to %q on %d snippets?", from, to, n),
+					onYes: func(m model) (tea.Model, tea.Cmd) {
+						n := renameTag(m.snippets, from, to)
+						m.status = fmt.Sprintf("Renamed %q to %q on %d snippets", from, to, n)
+						m.tagCursor = 0
+						m.tagSelected = map[string]bool{}
+						m.tagExcluded = map[string]bool{}
+						return m, m.persist()
+					},
+				})
+			}
 		case "view":
-			// No additional handling needed here, Esc is handled globally
+			// Whatever the key does to the selected snippet, it has its
+			// code to do it with
+			if v := m.visibleSnippets(); len(m.codeless) > 0 && m.selectedItem >= 0 && m.selectedItem < len(v) {
+				m.fillCode(v[m.selectedItem].ID)
+			}
+			if m.taggingID != 0 {
+				suggestions := m.suggestions()
+				switch {
+				case key.Matches(msg, keys.SuggestUp):
+					if m.suggestion > 0 {
+						m.suggestion--
+					}
+					return m, nil
+				case key.Matches(msg, keys.SuggestDown):
+					if m.suggestion < len(suggestions)-1 {
+						m.suggestion++
+					}
+					return m, nil
+				case key.Matches(msg, keys.Complete):
+					if m.suggestion < len(suggestions) {
+						m.input.SetValue(suggestions[m.suggestion])
+						m.input.CursorEnd()
+					}
+					m.suggestion = 0
+					return m, nil
+				case key.Matches(msg, keys.Submit):
+					return m.applyTagInput()
+				}
+				m.suggestion = 0
+				var cmd tea.Cmd
+				m.input, cmd = m.input.Update(msg)
+				return m, cmd
+			}
+			if m.searching {
+				switch msg.Type {
+				case tea.KeyCtrlL:
+					return m.clearFilters(), nil
+				case tea.KeyEnter:
+					// Don't leave the list showing results for what was
+					// typed before the last pause
+					m.searching = false
+					m.search.Blur()
+					m.query = m.search.Value()
+					if m.query != "" {
+						m.fillAllCode()
+					}
+					return m, nil
+				case tea.KeyUp, tea.KeyDown:
+					// Arrows still move through the results while typing
+				default:
+					var cmd tea.Cmd
+					m.search, cmd = m.search.Update(msg)
+					if m.search.Value() == m.query {
+						return m, cmd
+					}
+					m.searchSeq++
+					seq := m.searchSeq
+					return m, tea.Batch(cmd, tea.Tick(searchDebounce, func(time.Time) tea.Msg {
+						return searchTickMsg(seq)
+					}))
+				}
+			}
+			visible := m.visibleSnippets()
+			switch {
+			case key.Matches(msg, keys.ClearFilters):
+				return m.clearFilters(), nil
+			case m.query != "" && key.Matches(msg, keys.NextMatch, keys.PrevMatch):
+				// Step through the results like a pager, wrapping round
+				if n := len(visible); n > 0 {
+					step := 1
+					if key.Matches(msg, keys.PrevMatch) {
+						step = n - 1
+					}
+					m.selectedItem = (m.selectedItem + step) % n
+					m.revealed = false
+				}
+			case key.Matches(msg, keys.Up):
+				if m.selectedItem > 0 {
+					m.selectedItem--
+					m.revealed = false
+				}
+			case key.Matches(msg, keys.Down):
+				if m.selectedItem < len(visible)-1 {
+					m.selectedItem++
+					m.revealed = false
+				}
+			case key.Matches(msg, keys.Reveal):
+				m.revealed = !m.revealed
+			case key.Matches(msg, keys.Pager):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					cmd := m.page(visible[m.selectedItem])
+					return m, cmd
+				}
+				return m, nil
+			case key.Matches(msg, keys.Run):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					return m.copyAndRun(visible[m.selectedItem].ID, visible[m.selectedItem])
+				}
+				return m, nil
+			case key.Matches(msg, keys.Sensitive):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					m.revealed = false
+					return m, m.toggleSensitive(visible[m.selectedItem].ID)
+				}
+			case key.Matches(msg, keys.CopySnippet):
+				// Copying is what the list is mostly for, so it gets Enter
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					snip := visible[m.selectedItem]
+					return m.copyCode(snip.ID, snip.Code, strconv.Quote(snip.Name), m.pick)
+				}
+				return m, nil
+			case key.Matches(msg, keys.CopyHeader):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					snip := visible[m.selectedItem]
+					return m.copyCode(snip.ID, withNameHeader(snip, snip.Code), strconv.Quote(snip.Name)+" with a name comment", m.pick)
+				}
+				return m, nil
+			case key.Matches(msg, keys.Details):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					m.revealed = false
+					m.navigate("detail")
+					m.detailID = visible[m.selectedItem].ID
+					m.block = 0
+					m.lineCursor = 0
+					m.selectAnchor = -1
+					m.finding = false
+					m.findQuery = ""
+				}
+				return m, nil
+			case key.Matches(msg, keys.Density):
+				m.density = (m.density + 1) % 3
+			case key.Matches(msg, keys.Whitespace):
+				m.whitespace = !m.whitespace
+			case key.Matches(msg, keys.Wrap):
+				m.toggleWrap()
+			case key.Matches(msg, keys.ScrollLeft, keys.ScrollRight):
+				step := hscrollStep
+				if key.Matches(msg, keys.ScrollLeft) {
+					step = -step
+				}
+				m.scrollSideways(step)
+			case key.Matches(msg, keys.LineNumbers):
+				m.toggleLineNumbers()
+			case key.Matches(msg, keys.Colors):
+				m.cfg.LanguageColors = !m.cfg.LanguageColors
+				if err := saveConfigValue("languageColors", m.cfg.LanguageColors); err != nil {
+					m.err = fmt.Errorf("couldn't remember the color setting: %w", err)
+				}
+				return m, nil
+			case key.Matches(msg, keys.Theme):
+				m.cycleTheme()
+				return m, nil
+			case key.Matches(msg, keys.Sort):
+				var id int
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					id = visible[m.selectedItem].ID
+				}
+				m.cfg.SortMode = nextSortMode(m.cfg.SortMode)
+				m.selectedItem = max(indexOf(m.visibleSnippets(), id), 0)
+				if err := saveConfigValue("sortMode", m.cfg.SortMode); err != nil {
+					m.err = fmt.Errorf("couldn't remember the sort order: %w", err)
+				}
+				return m, nil
+			case key.Matches(msg, keys.DateFilter):
+				return m.openDateFilter(), nil
+			case key.Matches(msg, keys.TagSnippet):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					m.taggingID = visible[m.selectedItem].ID
+					m.suggestion = 0
+					m.input.Placeholder = "Tag to add, or one it has to remove"
+					m.input.SetValue("")
+					return m, m.input.Focus()
+				}
+				return m, nil
+			case key.Matches(msg, keys.Search):
+				m.searching = true
+				m.search.CursorEnd()
+				return m, m.search.Focus()
+			case key.Matches(msg, keys.Compare):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					clip, err := readClipboard()
+					if err != nil {
+						m.err = fmt.Errorf("couldn't read clipboard: %w", err)
+						return m, nil
+					}
+					m.detailID = visible[m.selectedItem].ID
+					m.diff = diffLines(visible[m.selectedItem].Code, clip)
+					m.navigate("diff")
+				}
+				return m, nil
+			case key.Matches(msg, keys.CopyID):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					id := visible[m.selectedItem].ID
+					m.copyField(fmt.Sprintf("ID %d", id), strconv.Itoa(id))
+				}
+				return m, nil
+			case key.Matches(msg, keys.CopyLanguage):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					lang := visible[m.selectedItem].Language
+					if !m.fillAllCode() {
+						return m, nil
+					}
+					text, n := bundleLanguage(m.visibleSnippets(), lang)
+					m.copyField(fmt.Sprintf("%d %s snippets", n, lang), text)
+				}
+				return m, nil
+			case key.Matches(msg, keys.Pin):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					id := visible[m.selectedItem].ID
+					cmd := m.togglePin(id)
+					m.selectedItem = indexOf(m.visibleSnippets(), id)
+					return m, cmd
+				}
+			case key.Matches(msg, keys.Archive):
+				if m.selectedItem >= 0 && m.selectedItem < len(visible) {
+					cmd := m.toggleArchive(visible[m.selectedItem].ID)
+					// Archiving hides it unless archived ones are shown
+					if n := len(m.visibleSnippets()); m.selectedItem >= n {
+						m.selectedItem = max(n-1, 0)
+					}
+					return m, cmd
+				}
+			case key.Matches(msg, keys.ShowArchived):
+				m.showArchived = !m.showArchived
+				m.selectedItem = 0
+			case key.Matches(msg, keys.Incomplete):
+				// Missing code can't be told from code not loaded yet
+				if !m.fillAllCode() {
+					return m, nil
+				}
+				m.incomplete = !m.incomplete
+				m.selectedItem = 0
+				if n := countIncomplete(m.snippets); m.incomplete {
+					m.status = fmt.Sprintf("%d of %d snippets are missing a language or code", n, len(m.snippets))
+				}
+			}
+		case "block":
+			return m.updateBlock(msg)
+		case "detail":
+			idx := m.findSnippet(m.detailID)
+			if idx < 0 {
+				return m.resetState(), nil
+			}
+			// Everything here acts on the language being shown
+			shown := shownBlock(m.snippets[idx], m.block)
+			if m.finding {
+				return m.updateFind(msg, shown.Code)
+			}
+			lines := strings.Split(shown.Code, "\n")
+			if m.markdownShown(shown) && key.Matches(msg, keys.SelectMode, keys.SelectUp, keys.SelectDown) {
+				// Lines are picked from the code, so show it
+				m.rawMarkdown = true
+			}
+			switch {
+			case key.Matches(msg, keys.Up, keys.SelectUp):
+				if key.Matches(msg, keys.SelectUp) && m.selectAnchor < 0 {
+					m.selectAnchor = m.lineCursor
+				}
+				if m.lineCursor > 0 {
+					m.lineCursor--
+				}
+			case key.Matches(msg, keys.Down, keys.SelectDown):
+				if key.Matches(msg, keys.SelectDown) && m.selectAnchor < 0 {
+					m.selectAnchor = m.lineCursor
+				}
+				if m.lineCursor < len(lines)-1 {
+					m.lineCursor++
+				}
+			case key.Matches(msg, keys.SelectMode):
+				if m.selectAnchor < 0 {
+					m.selectAnchor = m.lineCursor
+				} else {
+					m.selectAnchor = -1
+				}
+			case key.Matches(msg, keys.Copy, keys.CopyHeader):
+				text, what := shown.Code, "snippet"
+				if len(shown.Blocks) > 0 {
+					what = shown.Language + " version"
+				}
+				if m.selectAnchor >= 0 {
+					start, end := m.selectedRange()
+					text = codeLines(text, start, end)
+					what = fmt.Sprintf("lines %d-%d", start+1, end+1)
+				}
+				if key.Matches(msg, keys.CopyHeader) {
+					text = withNameHeader(shown, text)
+					what += " with a name comment"
+				}
+				m.selectAnchor = -1
+				return m.copyCode(m.snippets[idx].ID, text, what, false)
+			case key.Matches(msg, keys.CopyID):
+				id := m.snippets[idx].ID
+				m.copyField(fmt.Sprintf("ID %d", id), strconv.Itoa(id))
+			case key.Matches(msg, keys.Reveal):
+				m.revealed = !m.revealed
+			case key.Matches(msg, keys.Pager):
+				cmd := m.page(shown)
+				return m, cmd
+			case key.Matches(msg, keys.Run):
+				return m.copyAndRun(m.snippets[idx].ID, shown)
+			case key.Matches(msg, keys.Whitespace):
+				m.whitespace = !m.whitespace
+			case key.Matches(msg, keys.Wrap):
+				m.toggleWrap()
+			case key.Matches(msg, keys.ScrollLeft, keys.ScrollRight):
+				step := hscrollStep
+				if key.Matches(msg, keys.ScrollLeft) {
+					step = -step
+				}
+				m.scrollSideways(step)
+			case key.Matches(msg, keys.LineNumbers):
+				m.toggleLineNumbers()
+			case key.Matches(msg, keys.RawMarkdown) && isMarkdown(shown):
+				m.rawMarkdown = !m.rawMarkdown
+			case key.Matches(msg, keys.Search):
+				return m.openFind()
+			case m.findQuery != "" && key.Matches(msg, keys.NextMatch, keys.PrevMatch):
+				m.nextFind(shown.Code, key.Matches(msg, keys.PrevMatch))
+			case key.Matches(msg, keys.NextBlock) && len(shown.Blocks) > 0:
+				m.showBlock((m.block + 1) % (len(shown.Blocks) + 1))
+			case key.Matches(msg, keys.Rate):
+				rating, _ := strconv.Atoi(msg.String())
+				cmd := m.rate(m.detailID, rating)
+				return m, cmd
+			case key.Matches(msg, keys.AddBlock):
+				return m.openBlock()
+			case key.Matches(msg, keys.RemoveBlock):
+				return m.removeBlock()
+			case key.Matches(msg, keys.OpenSource):
+				m.openSource(m.snippets[idx])
+			case key.Matches(msg, keys.EditSource):
+				m.navigate("source")
+				m.input.Placeholder = "Source URL or reference (empty clears)"
+				m.input.SetValue(m.snippets[idx].Source)
+				m.input.CursorEnd()
+				return m, m.input.Focus()
+			case key.Matches(msg, keys.AddNote):
+				m.navigate("note")
+				m.input.Placeholder = "Note"
+				m.input.SetValue("")
+				m.input.Focus()
+			}
+			return m, nil
 		}
 	}
 
 	var cmd tea.Cmd
 	m.list, cmd = m.list.Update(msg)
+	if m.state == "rename" || m.state == "tagedit" || m.state == "newcollection" || m.state == "datefilter" || m.state == "palette" || m.state == "note" || m.state == "fill" || m.state == "source" {
+		m.input, cmd = m.input.Update(msg)
+	}
 	if m.state == "add" {
-		if m.currentField < 2 {
+		if m.currentField < fieldCode {
 			m.input, cmd = m.input.Update(msg)
+			if m.currentField == fieldTags && strings.Contains(m.input.Value(), ",") {
+				// A comma finishes a tag, including in pasted lists
+				parts := strings.Split(m.input.Value(), ",")
+				for _, t := range parts[:len(parts)-1] {
+					m.newSnippet.Tags = addTag(m.newSnippet.Tags, t)
+				}
+				m.input.SetValue(parts[len(parts)-1])
+			}
 		} else {
 			m.textarea, cmd = m.textarea.Update(msg)
 		}
@@ -237,48 +1379,404 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) View() string {
+	if m.tooSmall {
+		return fmt.Sprintf("Terminal too small (need at least %dx%d, have %dx%d)", minWidth, minHeight, m.width, m.height)
+	}
+
+	screen := m.screenView()
+	if m.err != nil {
+		screen += "\n" + errorStyle.Render("Error: "+m.err.Error())
+	}
+	footer := "Collection: " + m.collection
+	if m.readOnly {
+		footer += " · read-only"
+	}
+	return screen + "\n" + footerStyle.Render(footer)
+}
+
+// screenView renders the screen for the current state, without the footer.
+func (m model) screenView() string {
 	switch m.state {
+	case "welcome":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Welcome to SnipSnap"))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(strings.Join([]string{
+			"SnipSnap keeps the bits of code you reach for again and again.",
+			"",
+			"  Add Snippet     save a name, language, tags and the code",
+			"  View Snippets   browse them and copy one with Enter",
+			"  Browse Tags     narrow the list down by tag",
+			"",
+			"Snippets are stored in " + m.backend.Location() + " in this directory.",
+		}, "\n")) + "\n")
+		s.WriteString(m.keyHints())
+		return s.String()
 	case "menu":
-		return m.list.View()
+		l := m.list
+		l.Title = m.decorateTitle(l.Title)
+		// The list draws its own help, so a question goes underneath
+		if m.confirm != nil {
+			return l.View() + "\n" + m.confirmView()
+		}
+		if m.status != "" {
+			return l.View() + "\n" + itemStyle.Render(m.status)
+		}
+		return l.View()
 	case "view":
 		var s strings.Builder
-		s.WriteString(titleStyle.Render("View Snippets"))
+		title := "View Snippets"
+		if len(m.tagFilter) > 0 {
+			title += " tagged " + strings.Join(m.tagFilter, " + ")
+		}
+		if len(m.tagExclude) > 0 {
+			title += " without " + strings.Join(m.tagExclude, ", ")
+		}
+		if m.langFilter != "" {
+			title += " in " + m.langFilter
+		}
+		if m.dateFilter != nil {
+			title += " added " + m.dateFilter.String()
+		}
+		if m.incomplete {
+			title += " missing a language or code"
+		}
+		if m.showArchived {
+			title += ", archived included"
+		}
+		s.WriteString(m.renderTitle(title))
 		s.WriteString("\n\n")
-		for _, snip := range m.snippets {
-			s.WriteString(itemStyle.Render(fmt.Sprintf("ID: %d\nName: %s\nLanguage: %s\nCode:\n", snip.ID, snip.Name, snip.Language)))
-
-			// Split the code into lines and render each line
-			codeLines := strings.Split(snip.Code, "\n")
-			for _, line := range codeLines {
-				s.WriteString(itemStyle.Render(line + "\n"))
+		visible := m.visibleSnippets()
+		if m.searching || m.search.Value() != "" {
+			s.WriteString(m.search.View() + "\n")
+			if m.query != "" {
+				s.WriteString(placeholderStyle.Render(matchCount(m.selectedItem, len(visible))) + "\n")
 			}
-
-			s.WriteString(itemStyle.Render("----------------------\n"))
+			s.WriteString("\n")
 		}
-		s.WriteString(quitTextStyle.Render("Press 'esc' to return to menu"))
-		return s.String()
-	case "add":
-		var s strings.Builder
-		s.WriteString(titleStyle.Render("Add Snippet"))
-		s.WriteString("\n\n")
-		prompt := ""
-		switch m.currentField {
-		case 0:
-			prompt = "Enter snippet name"
-			s.WriteString(itemStyle.Render(fmt.Sprintf("%s:\n%s\n", prompt, m.input.View())))
-		case 1:
+		if i := m.findSnippet(m.taggingID); i >= 0 {
+			s.WriteString(itemStyle.Render(fmt.Sprintf("Tag %s (has: %s):\n%s", displayName(m.snippets[i]), strings.Join(m.snippets[i].Tags, ", "), m.input.View())) + "\n")
+			if suggestions := m.suggestionsView(); suggestions != "" {
+				s.WriteString(suggestions + "\n")
+			}
+			s.WriteString("\n")
+		}
+		// Entries are laid out as lines first, so only a window of them
+		// around the selected one needs to fit on screen
+		var body []string
+		selFrom, selTo := 0, 0
+		for i, snip := range visible {
+			if i == m.selectedItem {
+				selFrom = len(body)
+			}
+			header := itemStyle
+			name := displayName(snip)
+			if len(placeholders(snip.Code)) > 0 {
+				name += " [template]"
+			}
+			if snip.Archived {
+				name += " [archived]"
+			}
+			if snip.Rating > 0 {
+				name += " " + ratingStars(snip.Rating)
+			}
+			if m.selectedItem == i {
+				header = selectedItemStyle
+			} else if m.cfg.LanguageColors {
+				// The selected entry keeps the selection color so it
+				// still stands out
+				name = languageStyle(snip.Language).Render(name)
+			}
+			// Every line of an entry is drawn on its own, with a cursor
+			// on the first line of the selected one
+			first := true
+			write := func(style lipgloss.Style, text string) {
+				for _, line := range strings.Split(text, "\n") {
+					body = append(body, style.Render(cursorMark(first && m.selectedItem == i)+line))
+					first = false
+				}
+				if i == m.selectedItem {
+					selTo = len(body)
+				}
+			}
+			switch m.density {
+			case densityCompact:
+				// One line each: the name, then as much of the code's
+				// first line as fits, or the mask for sensitive snippets
+				line := fitWidth(name, m.lineWidth()-2)
+				room := m.lineWidth() - 2 - lipgloss.Width(line) - 2
+				preview := fitWidth(maskedCode, room)
+				if !snip.Sensitive || (m.revealed && m.selectedItem == i) {
+					preview = codePreview(snip.Code, room)
+				}
+				if preview != "" && (m.width == 0 || room > 0) {
+					line += "  " + placeholderStyle.Render(preview)
+				}
+				write(header, line)
+				continue
+			case densityVerbose:
+				lines := strings.Count(snip.Code, "\n") + 1
+				added := "unknown"
+				if !snip.CreatedAt.IsZero() {
+					added = snip.CreatedAt.Format("2006-01-02 15:04")
+				}
+				write(header, fmt.Sprintf("ID: %d\nName: %s\nLanguage: %s\nTags: %s\nAdded: %s\nSize: %d lines, %d bytes", snip.ID, name, snip.Language, strings.Join(snip.Tags, ", "), added, lines, len(snip.Code)))
+				if snip.Source != "" {
+					write(header, "Source: "+snip.Source)
+				}
+				if snip.Notes != "" {
+					write(header, "Notes:\n"+snip.Notes)
+				}
+				write(header, "Code:")
+			default:
+				write(header, fmt.Sprintf("ID: %d\nName: %s\nLanguage: %s\nCode:", snip.ID, name, snip.Language))
+			}
+
+			switch {
+			case snip.Sensitive && !(m.revealed && m.selectedItem == i):
+				write(placeholderStyle.PaddingLeft(4), maskedCode)
+			case m.codeless[snip.ID]:
+				write(placeholderStyle.PaddingLeft(4), "loading code…")
+			default:
+				var lines []string
+				for _, line := range m.renderedLines(snip) {
+					lines = append(lines, m.layoutLine(line, m.codeWidth())...)
+				}
+				write(itemStyle, strings.Join(lines, "\n"))
+			}
+			write(itemStyle, "----------------------")
+		}
+		tail := m.keyHints()
+		if m.status != "" {
+			tail = itemStyle.Render(m.status) + "\n" + tail
+		}
+		for _, line := range windowLines(body, selFrom, selTo, m.bodyRoom(s.String(), tail)) {
+			s.WriteString(line + "\n")
+		}
+		s.WriteString(tail)
+		return s.String()
+	case "detail":
+		idx := m.findSnippet(m.detailID)
+		if idx < 0 {
+			return "Snippet not found"
+		}
+		snip := shownBlock(m.snippets[idx], m.block)
+		var s strings.Builder
+		s.WriteString(m.renderTitle(snip.Name))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(fmt.Sprintf("ID: %d\nLanguage: %s", snip.ID, snip.Language)) + "\n")
+		s.WriteString(blockTabs(m.snippets[idx], m.block))
+		if snip.Rating > 0 {
+			s.WriteString(placeholderStyle.PaddingLeft(4).Render("rated "+ratingStars(snip.Rating)) + "\n")
+		}
+		if snip.UID != "" {
+			s.WriteString(placeholderStyle.PaddingLeft(4).Render("UID: "+snip.UID) + "\n")
+		}
+		if snip.Source != "" {
+			s.WriteString(placeholderStyle.PaddingLeft(4).Render("from "+snip.Source) + "\n")
+		}
+		s.WriteString(placeholderStyle.PaddingLeft(4).Render("exports as "+snippetFilename(snip, m.cfg.Extensions)) + "\n")
+		if !snip.LastUsedAt.IsZero() {
+			s.WriteString(placeholderStyle.PaddingLeft(4).Render("used "+usedAgo(snip.LastUsedAt, time.Now())) + "\n")
+		}
+		s.WriteString("\n")
+		found := findLines(snip.Code, m.findQuery)
+		if m.finding {
+			s.WriteString(itemStyle.Render(m.find.View()) + "\n")
+		}
+		if m.findQuery != "" {
+			s.WriteString(placeholderStyle.PaddingLeft(4).Render(matchCount(findPosition(found, m.lineCursor), len(found))) + "\n\n")
+		}
+
+		// The code and notes are laid out as lines first, so a viewport
+		// onto them can keep the cursor in sight however long they are
+		var body []string
+		cursorRow := 0
+		start, end := m.selectedRange()
+		for i, line := range m.renderedLines(snip) {
+			if snip.Sensitive && !m.revealed {
+				body = append(body, placeholderStyle.Render("  "+maskedCode))
+				break
+			}
+			if m.markdownShown(snip) {
+				// Rendered Markdown has no cursor, so the cursor line
+				// scrolls through it roughly
+				body = append(body, m.markdownLines(snip)...)
+				cursorRow = m.lineCursor
+				break
+			}
+			style := itemStyle
+			if m.selectAnchor >= 0 && i >= start && i <= end {
+				style = selectedItemStyle
+			}
+			if i == m.lineCursor {
+				cursorRow = len(body)
+			}
+			// A wrapped line keeps the cursor on its first part
+			for j, part := range m.layoutLine(line, m.codeWidth()) {
+				if findPosition(found, i) >= 0 {
+					part = highlightMatches(part, m.findQuery, findMatchStyle)
+				}
+				body = append(body, style.Render(cursorMark(j == 0 && i == m.lineCursor)+part))
+			}
+		}
+		if snip.Notes != "" {
+			body = append(body, "")
+			body = append(body, strings.Split(placeholderStyle.PaddingLeft(4).Render("Notes:\n"+snip.Notes), "\n")...)
+		}
+
+		tail := m.keyHints()
+		if m.status != "" {
+			tail = "\n" + itemStyle.Render(m.status) + "\n" + tail
+		}
+		// One line goes to saying where in the body the viewport is
+		if room := m.bodyRoom(s.String(), tail) - 1; room > 0 && len(body) > room {
+			vp := viewport.New(m.width, room)
+			vp.SetContent(strings.Join(body, "\n"))
+			vp.SetYOffset(scrollStart(len(body), cursorRow, cursorRow+1, room))
+			s.WriteString(vp.View() + "\n")
+			s.WriteString(placeholderStyle.PaddingLeft(4).Render(fmt.Sprintf("lines %d-%d of %d", vp.YOffset+1, vp.YOffset+room, len(body))) + "\n")
+		} else {
+			for _, line := range body {
+				s.WriteString(line + "\n")
+			}
+		}
+		s.WriteString(tail)
+		return s.String()
+	case "add":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Add Snippet"))
+		s.WriteString("\n\n")
+		prompt := ""
+		switch m.currentField {
+		case fieldName:
+			prompt = "Enter snippet name"
+			s.WriteString(itemStyle.Render(fmt.Sprintf("%s:\n%s\n", prompt, m.input.View())))
+		case fieldLanguage:
+			if m.pickingLang {
+				s.WriteString(m.langList.View() + "\n")
+				s.WriteString(m.keyHints())
+				break
+			}
 			prompt = "Enter snippet language"
+			s.WriteString(itemStyle.Render(fmt.Sprintf("%s:\n%s", prompt, m.input.View())) + "\n")
+			s.WriteString(m.suggestionsView())
+			s.WriteString(m.keyHints())
+		case fieldTags:
+			prompt = "Enter snippet tags"
+			var chips []string
+			for _, t := range m.newSnippet.Tags {
+				chips = append(chips, chipStyle.Render(t))
+			}
+			s.WriteString(itemStyle.Render(fmt.Sprintf("%s:\n%s\n%s", prompt, lipgloss.JoinHorizontal(lipgloss.Top, chips...), m.input.View())) + "\n")
+			s.WriteString(m.suggestionsView())
+			s.WriteString(m.keyHints())
+		case fieldSource:
+			prompt = "Enter snippet source"
 			s.WriteString(itemStyle.Render(fmt.Sprintf("%s:\n%s\n", prompt, m.input.View())))
-		case 2:
+			s.WriteString(m.keyHints())
+		case fieldCode:
 			prompt = "Enter snippet code"
 			s.WriteString(itemStyle.Render(fmt.Sprintf("%s:\n%s\n", prompt, m.textarea.View())))
-			s.WriteString(quitTextStyle.Render("(Press Ctrl+S to save, Esc to cancel)"))
+			if n, length := longestLine(m.textarea.Value()); length > longLineWarning {
+				s.WriteString(removedLineStyle.Render(fmt.Sprintf("Very long line detected (line %d, %d characters); it will be saved as is", n, length)) + "\n")
+			}
+			s.WriteString(m.keyHints())
+		}
+		s.WriteString("\n")
+		return s.String()
+	case "languages":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Browse Languages"))
+		s.WriteString("\n\n")
+
+		langs := countLanguages(m.snippets)
+		if len(langs) == 0 {
+			s.WriteString(itemStyle.Render("No snippets with a language yet") + "\n")
+		}
+		width := 0
+		for _, l := range langs {
+			width = max(width, lipgloss.Width(l.Language))
+		}
+		counted := 0
+		for i, l := range langs {
+			counted += l.Count
+			style := itemStyle
+			if m.langCursor == i {
+				style = selectedItemStyle
+			}
+			label := style.Render(padRight(l.Language, width))
+			bar := languageStyle(l.Language).Render(languageBar(l.Count, langs[0].Count))
+			s.WriteString(label + " " + bar + fmt.Sprintf(" %d", l.Count) + "\n")
+		}
+		if n := len(m.snippets) - counted; n > 0 {
+			s.WriteString("\n" + placeholderStyle.PaddingLeft(4).Render(fmt.Sprintf("%d without a language", n)) + "\n")
+		}
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "tags":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Browse Tags"))
+		s.WriteString("\n\n")
+
+		tags := countTags(m.snippets)
+		if len(tags) == 0 {
+			s.WriteString(itemStyle.Render("No tagged snippets yet") + "\n")
+		}
+		for i, t := range tags {
+			style := itemStyle
+			if m.tagCursor == i {
+				style = selectedItemStyle
+			}
+			check := "[ ]"
+			if m.tagSelected[t.Tag] {
+				check = "[x]"
+			} else if m.tagExcluded[t.Tag] {
+				check = "[-]"
+			}
+			s.WriteString(style.Render(fmt.Sprintf("%s %s (%d)", check, t.Tag, t.Count)) + "\n")
 		}
 		s.WriteString("\n")
+		if m.status != "" {
+			s.WriteString(itemStyle.Render(m.status) + "\n")
+		}
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "palette":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Command Palette"))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(m.input.View()) + "\n\n")
+		actions := filterPalette(m.input.Value(), paletteActions())
+		for i, a := range actions {
+			style := itemStyle
+			if i == m.paletteCursor {
+				style = selectedItemStyle
+			}
+			s.WriteString(style.Render(fitWidth(padRight(a.Name, 18)+" "+a.Desc, m.lineWidth())) + "\n")
+		}
+		if len(actions) == 0 {
+			s.WriteString(itemStyle.Render("No matching commands") + "\n")
+		}
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "datefilter":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Filter by Date Added"))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(fmt.Sprintf("Show snippets added:\n%s", m.input.View())) + "\n")
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "tagedit":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Rename Tag"))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(fmt.Sprintf("Rename %q to:\n%s\n", m.tagTarget, m.input.View())))
+		s.WriteString(m.keyHints())
 		return s.String()
 	case "delete":
 		var s strings.Builder
-		s.WriteString(titleStyle.Render("Delete Snippet"))
+		s.WriteString(m.renderTitle("Delete Snippet"))
 		s.WriteString("\n\n")
 
 		maxID := 0
@@ -289,24 +1787,806 @@ func (m model) View() string {
 		}
 		idWidth := len(strconv.Itoa(maxID))
 
-		for i, snip := range m.snippets {
+		for i, snip := range orderForDisplay(m.snippets, m.cfg.SortMode) {
 			style := itemStyle
 			if m.selectedItem == i {
 				style = selectedItemStyle
 			}
-			formattedLine := fmt.Sprintf("%-*d: %s", idWidth, snip.ID, snip.Name)
-			s.WriteString(style.Render(formattedLine) + "\n")
+			formattedLine := fmt.Sprintf("%-*d: %s", idWidth, snip.ID, displayName(snip))
+			if snip.Archived {
+				formattedLine += " (archived)"
+			}
+			if m.merging && snip.ID == m.mergeID {
+				formattedLine += " (merge into)"
+			}
+			// Wrapping would push the next IDs out of their column
+			s.WriteString(style.Render(cursorMark(m.selectedItem == i)+fitWidth(formattedLine, m.lineWidth()-2)) + "\n")
 		}
 		s.WriteString("\n")
-		s.WriteString(quitTextStyle.Render("Use arrow keys to select, Enter to delete, 'esc' to cancel"))
+		if m.status != "" {
+			s.WriteString(itemStyle.Render(m.status) + "\n")
+		}
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "fill":
+		return m.fillView()
+	case "pager":
+		return m.pagerView()
+	case "stale":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Stale Snippets"))
+		s.WriteString("\n\n")
+		now := time.Now()
+		stale := staleSnippets(m.snippets, m.cfg.StaleDays, now)
+		if len(stale) == 0 {
+			s.WriteString(itemStyle.Render(fmt.Sprintf("Every snippet has been used in the last %d days.", m.cfg.StaleDays)) + "\n")
+		} else {
+			s.WriteString(itemStyle.Render(fmt.Sprintf("Not copied in the last %d days, least recently used first:", m.cfg.StaleDays)) + "\n\n")
+		}
+		for i, snip := range stale {
+			line := fmt.Sprintf("%s  %s", displayName(snip), describeUse(snip, now))
+			style := itemStyle
+			if i == m.staleCursor {
+				style = selectedItemStyle
+			}
+			s.WriteString(style.Render(cursorMark(i == m.staleCursor)+line) + "\n")
+		}
+		if m.status != "" {
+			s.WriteString(itemStyle.Render(m.status) + "\n")
+		}
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "info":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Library Info"))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(libraryInfo(m.snippets, m.backend.Location())) + "\n")
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "insights":
+		return m.insightsView()
+	case "diff":
+		var s strings.Builder
+		name := ""
+		if i := m.findSnippet(m.detailID); i >= 0 {
+			name = m.snippets[i].Name
+		}
+		s.WriteString(m.renderTitle("Compare " + name + " with Clipboard"))
+		s.WriteString("\n\n")
+		changed := false
+		for _, line := range m.diff {
+			switch line.Op {
+			case diffAdded:
+				changed = true
+				s.WriteString(addedLineStyle.Render("+ "+line.Text) + "\n")
+			case diffRemoved:
+				changed = true
+				s.WriteString(removedLineStyle.Render("- "+line.Text) + "\n")
+			default:
+				s.WriteString(itemStyle.Render("  "+line.Text) + "\n")
+			}
+		}
+		if !changed {
+			s.WriteString("\n" + itemStyle.Render("The clipboard matches the snippet") + "\n")
+		}
+		s.WriteString(itemStyle.Render("'-' lines are only in the snippet, '+' lines only in the clipboard.") + "\n")
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "collections":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Switch Collection"))
+		s.WriteString("\n\n")
+		for i, name := range listCollections() {
+			style := itemStyle
+			if m.collCursor == i {
+				style = selectedItemStyle
+			}
+			marker := "  "
+			if name == m.collection {
+				marker = "* "
+			}
+			s.WriteString(style.Render(marker+name) + "\n")
+		}
+		if m.status != "" {
+			s.WriteString("\n" + itemStyle.Render(m.status) + "\n")
+		}
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "newcollection":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("New Collection"))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(fmt.Sprintf("Enter collection name:\n%s\n", m.input.View())))
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "conflict":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Snippets Changed on Disk"))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(m.backend.Location()+" was modified by another process since it was loaded.\nYour changes have not been written.") + "\n")
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "block":
+		return m.blockView()
+	case "source":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Source"))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(fmt.Sprintf("Where is it from?\n%s\n", m.input.View())))
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "note":
+		name := ""
+		if i := m.findSnippet(m.detailID); i >= 0 {
+			name = m.snippets[i].Name
+		}
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Add Note to " + name))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(fmt.Sprintf("Enter note:\n%s\n", m.input.View())))
+		s.WriteString(m.keyHints())
+		return s.String()
+	case "rename":
+		var s strings.Builder
+		s.WriteString(m.renderTitle("Rename Snippet"))
+		s.WriteString("\n\n")
+		s.WriteString(itemStyle.Render(fmt.Sprintf("Enter new name:\n%s\n", m.input.View())))
+		s.WriteString(m.keyHints())
 		return s.String()
 	default:
 		return "Unknown state"
 	}
 }
 
+// suggestions returns the autocomplete candidates for the add flow step
+// or the view's tag input being edited.
+func (m model) suggestions() []string {
+	if m.state == "view" {
+		// Tagging from the view offers the snippet's own tags too, since
+		// entering one removes it
+		if m.taggingID != 0 {
+			return suggestTags(m.input.Value(), m.snippets, nil)
+		}
+		return nil
+	}
+	switch m.currentField {
+	case fieldLanguage:
+		return suggestLanguages(m.input.Value(), m.snippets)
+	case fieldTags:
+		return suggestTags(m.input.Value(), m.snippets, m.newSnippet.Tags)
+	}
+	return nil
+}
+
+// suggestionsView lists the current suggestions under an input.
+func (m model) suggestionsView() string {
+	suggestions := m.suggestions()
+	if len(suggestions) == 0 {
+		return ""
+	}
+	var s strings.Builder
+	for i, suggestion := range suggestions {
+		style := itemStyle
+		if i == m.suggestion {
+			style = selectedItemStyle
+		}
+		s.WriteString(style.Render("  "+suggestion) + "\n")
+	}
+	return strings.TrimSuffix(s.String(), "\n")
+}
+
+// openMenuItem goes to the screen behind a main menu entry. The command
+// palette uses it too so both stay in step.
+func (m model) openMenuItem(name string) (tea.Model, tea.Cmd) {
+	if m.readOnly && readOnlyItems[name] {
+		m.status = readOnlyStatus
+		return m, nil
+	}
+	switch name {
+	case "View Snippets":
+		m.navigate("view")
+		m.selectedItem = 0
+	case "Add Snippet":
+		m.navigate("add")
+		m.currentField = 0
+		m.newSnippet = snippet{}
+		m.input.Placeholder = "Name"
+		m.input.SetValue("")
+		m.input.Focus()
+	case "Browse Tags":
+		m.navigate("tags")
+		m.tagCursor = 0
+		m.tagSelected = map[string]bool{}
+		m.tagExcluded = map[string]bool{}
+	case "Browse Languages":
+		m.navigate("languages")
+		m.langCursor = 0
+	case "Delete Snippet":
+		m.navigate("delete")
+		m.selectedItem = 0
+	case "Switch Collection":
+		m.navigate("collections")
+		m.collCursor = 0
+	case "Library Info":
+		// Its sizes and the Insights counts come from every snippet's code
+		m.fillAllCode()
+		m.navigate("info")
+	case "Insights":
+		m.fillAllCode()
+		m.navigate("insights")
+	case "Stale Snippets":
+		m.navigate("stale")
+		m.staleCursor = 0
+	case "Quit":
+		return m.quit()
+	}
+	return m, nil
+}
+
+// takeID hands out the ID for a new snippet. It never repeats one given
+// out or saved before, even after the snippet holding it is deleted.
+func (m *model) takeID() int {
+	id := max(m.nextID, store.NextID(m.snippets))
+	m.nextID = id + 1
+	return id
+}
+
+// startCapture opens straight into the add flow for 'snipsnap capture',
+// with the clipboard, if it holds anything, already in the code field.
+// Saving or Esc then exits.
+func (m model) startCapture() model {
+	m.state = "menu"
+	m.navStack = nil
+	next, _ := m.openMenuItem("Add Snippet")
+	m = next.(model)
+	m.capture = true
+	if clip, err := readClipboard(); err == nil && strings.TrimSpace(clip) != "" {
+		m.textarea.SetValue(clip)
+	}
+	return m
+}
+
+// openDateFilter prompts for the view's date range, starting from the
+// current one.
+func (m model) openDateFilter() model {
+	m.navigate("datefilter")
+	m.input.Placeholder = "last 7d, 2024-01-01..2024-02-01, ..2024-01-01"
+	m.input.SetValue("")
+	if m.dateFilter != nil {
+		m.input.SetValue(m.dateFilter.String())
+		m.input.CursorEnd()
+	}
+	m.input.Focus()
+	return m
+}
+
+// switchCollection opens another collection. It refuses while the current
+// one has changes that haven't reached the disk yet.
+func (m model) switchCollection(name string) (tea.Model, tea.Cmd) {
+	if m.dirty || m.saving {
+		if m.state == "newcollection" {
+			m = m.back()
+		}
+		m.status = "Save your changes (Ctrl+S) before switching collections"
+		return m, nil
+	}
+	backend, err := openCollection(name, m.cfg.Storage, m.readOnly)
+	if err != nil {
+		m.err = fmt.Errorf("couldn't open %s: %w", name, err)
+		return m, nil
+	}
+	snippets, version, codeless, codeLoad, err := loadLibrary(backend)
+	if err != nil {
+		closeStore(backend)
+		m.err = fmt.Errorf("couldn't open %s: %w", name, err)
+		return m, nil
+	}
+	closeStore(m.backend)
+	m.render.clear()
+	m.collection = name
+	m.backend = backend
+	m.snippets = snippets
+	m.codeless = codeless
+	m.diskVersion = version
+	m.nextID = backend.NextID()
+	m.err = damagedCodeError(snippets)
+	return m.resetState(), codeLoad
+}
+
+// persist is called after every change to m.snippets. In immediate mode it
+// writes straight away; in debounce mode it marks the model dirty and
+// schedules a save tick, so only the last change in a burst hits the disk;
+// in manual mode it only marks the model dirty until Ctrl+S. Code
+// is tidied first if the collection's config asks for it, and snippets
+// new since the last call get their UIDs. In read-only mode it does
+// nothing.
+func (m *model) persist() tea.Cmd {
+	if m.readOnly {
+		return nil
+	}
+	if len(m.codeless) > 0 {
+		// Saving now would write snippets without their code, so wait
+		// for it; codeLoaded saves once it arrives
+		m.dirty = true
+		return nil
+	}
+	store.AssignUIDs(m.snippets)
+	cc := m.cfg.collection(m.collection)
+	for i := range m.snippets {
+		// Damaged code is kept byte for byte until it is repaired
+		if !m.snippets[i].Corrupt {
+			m.snippets[i].Code = cc.cleanCode(m.snippets[i].Code)
+		}
+	}
+	m.index.sync(m.snippets)
+
+	switch m.cfg.SaveMode {
+	case saveModeManual:
+		m.dirty = true
+		return nil
+	case saveModeDebounce:
+	default:
+		version, err := m.backend.Save(m.snippets, m.diskVersion)
+		if err != nil {
+			// Report it like a background save so the conflict screen
+			// wins over whatever state the caller moves to next
+			return func() tea.Msg { return savedMsg{err: err} }
+		}
+		m.diskVersion = version
+		return nil
+	}
+	m.dirty = true
+	m.saveSeq++
+	seq := m.saveSeq
+	return tea.Tick(saveDebounce, func(time.Time) tea.Msg {
+		return saveTickMsg(seq)
+	})
+}
+
+// startSave writes out pending changes in the background, showing the
+// spinner until savedMsg comes back. Only one save runs at a time; changes
+// made while it is in flight stay dirty and are picked up when it finishes.
+func (m *model) startSave() tea.Cmd {
+	if m.saving || !m.dirty || len(m.codeless) > 0 {
+		return nil
+	}
+	m.saving = true
+	m.dirty = false
+	store.AssignUIDs(m.snippets)
+	snippets := append([]snippet(nil), m.snippets...)
+	backend, since := m.backend, m.diskVersion
+	return tea.Batch(m.spinner.Tick, func() tea.Msg {
+		version, err := backend.Save(snippets, since)
+		return savedMsg{version: version, err: err}
+	})
+}
+
+// quit waits for pending and in-flight saves before exiting so nothing is
+// lost to the debounced save mode. In manual mode writing is left to the
+// user, so unsaved changes get a warning instead of being saved.
+func (m model) quit() (tea.Model, tea.Cmd) {
+	if !m.saving && !m.dirty {
+		return m, tea.Quit
+	}
+	if m.dirty && m.cfg.SaveMode == saveModeManual {
+		return m.ask(confirmation{
+			prompt: "You have unsaved changes. Quit anyway?",
+			onYes: func(m model) (tea.Model, tea.Cmd) {
+				return m, tea.Quit
+			},
+		})
+	}
+	return m.saveAndQuit()
+}
+
+// saveAndQuit exits once pending and in-flight saves are done, whatever
+// the save mode.
+func (m model) saveAndQuit() (tea.Model, tea.Cmd) {
+	if !m.saving && !m.dirty {
+		return m, tea.Quit
+	}
+	if m.codeErr != nil {
+		// Saving would write snippets without the code that never came
+		return m.ask(confirmation{
+			prompt: "Snippet code didn't load, so changes can't be saved. Quit without saving?",
+			onYes: func(m model) (tea.Model, tea.Cmd) {
+				return m, tea.Quit
+			},
+		})
+	}
+	m.quitting = true
+	return m, m.startSave()
+}
+
+// visibleSnippets returns the snippets the view lists, after any tag,
+// language, date and search filters have been applied. Archived snippets
+// are left out unless they have been asked for.
+func (m model) visibleSnippets() []snippet {
+	matches := m.index.match(m.query)
+	var visible []snippet
+	for _, s := range m.snippets {
+		if s.Archived && !m.showArchived {
+			continue
+		}
+		if !s.HasTags(m.tagFilter) || hasAnyTag(s.Tags, m.tagExclude) {
+			continue
+		}
+		if m.dateFilter != nil && !m.dateFilter.contains(s.CreatedAt) {
+			continue
+		}
+		if m.incomplete && !isIncomplete(s) {
+			continue
+		}
+		if m.langFilter != "" && !s.HasLanguage(m.langFilter) {
+			continue
+		}
+		if matches != nil && !matches[s.ID] {
+			continue
+		}
+		visible = append(visible, s)
+	}
+	return orderForDisplay(visible, m.cfg.SortMode)
+}
+
+// applyTagInput toggles the typed tag on the snippet being tagged from the
+// view and saves.
+func (m model) applyTagInput() (tea.Model, tea.Cmd) {
+	i := m.findSnippet(m.taggingID)
+	tag := store.NormalizeTag(m.input.Value())
+	if i < 0 || tag == "" {
+		return m.closeTagInput(), nil
+	}
+	var added bool
+	m.snippets[i].Tags, added = toggleTag(m.snippets[i].Tags, tag)
+	if added {
+		m.status = fmt.Sprintf("Tagged %s with %s", displayName(m.snippets[i]), tag)
+	} else {
+		m.status = fmt.Sprintf("Removed %s from %s", tag, displayName(m.snippets[i]))
+	}
+	cmd := m.persist()
+	m = m.closeTagInput()
+	// Removing a tag the view is filtered by can hide the snippet
+	if n := len(m.visibleSnippets()); m.selectedItem >= n {
+		m.selectedItem = max(n-1, 0)
+	}
+	return m, cmd
+}
+
+func (m model) closeTagInput() model {
+	m.taggingID = 0
+	m.suggestion = 0
+	m.input.SetValue("")
+	m.input.Blur()
+	return m
+}
+
+// matchCount describes where the selection is among n search results,
+// e.g. "3/57 match".
+func matchCount(selected, n int) string {
+	if n == 0 {
+		return "No matches"
+	}
+	return fmt.Sprintf("%d/%d match", min(selected+1, n), n)
+}
+
+// filtered reports whether the view is narrowed by a search, tag,
+// language, date or incomplete filter.
+func (m model) filtered() bool {
+	return m.search.Value() != "" || len(m.tagFilter) > 0 || len(m.tagExclude) > 0 || m.langFilter != "" || m.dateFilter != nil || m.incomplete
+}
+
+// clearFilters drops the view's search, tag, language, date and
+// incomplete filters together, staying in the view.
+func (m model) clearFilters() model {
+	m.searching = false
+	m.search.SetValue("")
+	m.query = ""
+	m.search.Blur()
+	m.tagFilter = nil
+	m.tagExclude = nil
+	m.langFilter = ""
+	m.dateFilter = nil
+	m.incomplete = false
+	m.selectedItem = 0
+	m.revealed = false
+	return m
+}
+
+// codeForDisplay returns the lines of code as the view and detail screens
+// draw them: with whitespace made visible when 'w' has turned that on, and
+// numbered when line numbers are on.
+func (m model) codeForDisplay(code string) []string {
+	if m.whitespace {
+		code = markWhitespace(code)
+	}
+	lines := strings.Split(code, "\n")
+	if m.cfg.LineNumbers {
+		// Pad every number to the widest so the code stays in one column
+		width := len(strconv.Itoa(len(lines)))
+		for i, line := range lines {
+			lines[i] = fmt.Sprintf("%*d │ %s", width, i+1, line)
+		}
+	}
+	return lines
+}
+
+// renderedLines is codeForDisplay for a snippet, served from the render cache
+// when its code hasn't changed since it was last drawn. The lines are
+// shared with the cache and must not be modified.
+func (m model) renderedLines(s snippet) []string {
+	key := renderKey{id: s.ID, whitespace: m.whitespace, lineNumbers: m.cfg.LineNumbers}
+	return m.render.lines(key, s.Code, m.codeForDisplay)
+}
+
+// toggleLineNumbers switches line numbers on or off and remembers the
+// choice in the config.
+func (m *model) toggleLineNumbers() {
+	m.cfg.LineNumbers = !m.cfg.LineNumbers
+	if err := saveConfigValue("lineNumbers", m.cfg.LineNumbers); err != nil {
+		m.err = fmt.Errorf("couldn't remember the line number setting: %w", err)
+	}
+}
+
+var whitespaceMarkers = strings.NewReplacer(" ", "·", "\t", "→", "\r", "␍")
+
+// markWhitespace replaces spaces with ·, tabs with →, carriage returns
+// with ␍ and ends each line that has a newline with ↵, so stray
+// whitespace from a paste can be seen. Line breaks are kept.
+func markWhitespace(code string) string {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		line = whitespaceMarkers.Replace(line)
+		if i < len(lines)-1 {
+			line += "↵"
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// damagedCodeError warns about snippets whose code or notes couldn't be
+// decoded on load, or is nil when there are none.
+func damagedCodeError(snippets []snippet) error {
+	ids := store.Corrupted(snippets)
+	if len(ids) == 0 {
+		return nil
+	}
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = strconv.Itoa(id)
+	}
+	noun := "snippet"
+	if len(ids) > 1 {
+		noun = "snippets"
+	}
+	return fmt.Errorf("the stored code or notes of %s %s couldn't be decoded; they are shown and kept as stored, marked ⚠", noun, strings.Join(list, ", "))
+}
+
+// cursorMark goes in front of list lines: a pointer on the selected one,
+// so the cursor shows even without colors, and matching space elsewhere.
+func cursorMark(selected bool) string {
+	if selected {
+		return "> "
+	}
+	return "  "
+}
+
+// displayName is a snippet's name as shown in lists, starred when pinned
+// and flagged when its code or notes are damaged.
+func displayName(s snippet) string {
+	if s.Damaged() {
+		s.Name = "⚠ " + s.Name
+	}
+	if s.Pinned {
+		return "★ " + s.Name
+	}
+	return s.Name
+}
+
+// toggleSensitive marks or unmarks the snippet with the given ID as
+// sensitive, which hides its code until revealed.
+func (m *model) toggleSensitive(id int) tea.Cmd {
+	i := m.findSnippet(id)
+	if i < 0 {
+		return nil
+	}
+	m.snippets[i].Sensitive = !m.snippets[i].Sensitive
+	return m.persist()
+}
+
+// toggleArchive archives the snippet with the given ID, or brings it back
+// if it already is.
+func (m *model) toggleArchive(id int) tea.Cmd {
+	i := m.findSnippet(id)
+	if i < 0 {
+		return nil
+	}
+	m.snippets[i].Archived = !m.snippets[i].Archived
+	if m.snippets[i].Archived {
+		m.status = fmt.Sprintf("Archived %s", m.snippets[i].Name)
+	} else {
+		m.status = fmt.Sprintf("Restored %s", m.snippets[i].Name)
+	}
+	return m.persist()
+}
+
+// addSnippet saves the snippet being added with the code typed so far.
+func (m model) addSnippet() (tea.Model, tea.Cmd) {
+	m.newSnippet.Code = m.textarea.Value()
+	m.newSnippet.ID = m.takeID()
+	m.newSnippet.UID = store.NewUID()
+	m.newSnippet.CreatedAt = time.Now()
+	m.snippets = append(m.snippets, m.newSnippet)
+	cmd := m.persist()
+	// Capture mode is done once the snippet is saved; a failed immediate
+	// save stays to show the error
+	if m.capture && (cmd == nil || m.dirty) {
+		return m.saveAndQuit()
+	}
+	return m.resetState(), cmd
+}
+
+// markUsed stamps the snippet with the given ID as used just now.
+func (m *model) markUsed(id int) tea.Cmd {
+	i := m.findSnippet(id)
+	if i < 0 || m.readOnly {
+		return nil
+	}
+	m.snippets[i].LastUsedAt = time.Now()
+	if m.cfg.SaveMode == saveModeManual {
+		// Using a snippet isn't an edit to save by hand. With nothing else
+		// unsaved it is written out on its own; otherwise it goes with
+		// whatever is saved next.
+		if m.dirty || m.saving || len(m.codeless) > 0 {
+			return nil
+		}
+		m.dirty = true
+		return m.startSave()
+	}
+	return m.persist()
+}
+
+// togglePin pins or unpins the snippet with the given ID.
+func (m *model) togglePin(id int) tea.Cmd {
+	i := m.findSnippet(id)
+	if i < 0 {
+		return nil
+	}
+	m.snippets[i].Pinned = !m.snippets[i].Pinned
+	return m.persist()
+}
+
+// rate gives the snippet with the given ID rating stars, which callers
+// keep within store.ValidRating.
+func (m *model) rate(id, rating int) tea.Cmd {
+	i := m.findSnippet(id)
+	if i < 0 {
+		return nil
+	}
+	m.snippets[i].Rating = rating
+	m.status = fmt.Sprintf("Rated %q %d/%d", m.snippets[i].Name, rating, store.MaxRating)
+	return m.persist()
+}
+
+// ratingStars draws a rating as filled and empty stars, or nothing for
+// an unrated snippet.
+func ratingStars(rating int) string {
+	if rating <= 0 {
+		return ""
+	}
+	return strings.Repeat("★", rating) + strings.Repeat("☆", store.MaxRating-rating)
+}
+
+// indexOf returns the position of the snippet with the given ID in
+// snippets, or -1.
+func indexOf(snippets []snippet, id int) int {
+	for i, s := range snippets {
+		if s.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// decorateTitle appends the save status to a screen title: a spinner while
+// a save is running and a dot while there are unsaved changes.
+func (m model) decorateTitle(title string) string {
+	if m.saving {
+		return title + " " + m.spinner.View() + "saving"
+	}
+	if m.dirty {
+		return title + " •"
+	}
+	return title
+}
+
+func (m model) renderTitle(title string) string {
+	return titleStyle.Render(m.decorateTitle(title))
+}
+
+// findSnippet returns the index of the snippet with the given ID in
+// m.snippets, or -1.
+func (m model) findSnippet(id int) int {
+	return indexOf(m.snippets, id)
+}
+
+// selectedRange returns the first and last line of the detail selection in
+// ascending order. With no selection both are the cursor line.
+func (m model) selectedRange() (int, int) {
+	if m.selectAnchor < 0 {
+		return m.lineCursor, m.lineCursor
+	}
+	if m.selectAnchor < m.lineCursor {
+		return m.selectAnchor, m.lineCursor
+	}
+	return m.lineCursor, m.selectAnchor
+}
+
+// editingText reports whether keystrokes are currently going into a text
+// field, in which case single-letter shortcuts like 'q' must not fire.
+func (m model) editingText() bool {
+	switch m.state {
+	case "add", "rename", "tagedit", "newcollection", "datefilter", "palette", "note", "fill", "block", "source":
+		return true
+	case "view":
+		return m.searching || m.taggingID != 0
+	case "detail":
+		return m.finding
+	}
+	return false
+}
+
+// navigate moves to state, remembering the current screen so Esc can
+// return to it.
+func (m *model) navigate(state string) {
+	m.navStack = append(m.navStack, m.state)
+	m.state = state
+}
+
+// back returns to the screen we navigated from. Reaching the menu resets
+// everything, as does backing out of a screen with no history.
+func (m model) back() model {
+	m.input.SetValue("")
+	m.input.Blur()
+	m.revealed = false
+	m.fill = nil
+	if m.state == "view" {
+		m.tagFilter = nil
+		m.tagExclude = nil
+		m.langFilter = ""
+		m.dateFilter = nil
+		m.incomplete = false
+		m.searching = false
+		m.search.SetValue("")
+		m.query = ""
+		m.search.Blur()
+	}
+
+	if len(m.navStack) == 0 {
+		return m.resetState()
+	}
+	prev := m.navStack[len(m.navStack)-1]
+	m.navStack = m.navStack[:len(m.navStack)-1]
+	if prev == "menu" {
+		return m.resetState()
+	}
+	m.state = prev
+	return m
+}
+
 func (m model) resetState() model {
 	m.state = "menu"
+	m.navStack = nil
+	m.tagFilter = nil
+	m.tagExclude = nil
+	m.langFilter = ""
+	m.dateFilter = nil
+	m.incomplete = false
+	m.searching = false
+	m.search.SetValue("")
+	m.query = ""
+	m.search.Blur()
+	m.index.sync(m.snippets)
 	m.currentField = 0
 	m.newSnippet = snippet{}
 	m.input.SetValue("")
@@ -316,11 +2596,42 @@ func (m model) resetState() model {
 }
 
 func main() {
-	initialModel, err := initialModel()
+	if handled, err := runCLI(os.Args[1:]); handled {
+		if err != nil {
+			// Keep stdout clean for commands that are piped
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// capture is interactive, so it is handled here rather than by runCLI
+	args := os.Args[1:]
+	capture := len(args) > 0 && args[0] == "capture"
+	if capture {
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("snipsnap", flag.ExitOnError)
+	collection := fs.String("collection", "", "name of the snippet collection to open")
+	pick := fs.Bool("pick", false, "open the list, copy the chosen snippet and exit")
+	readOnly := fs.Bool("read-only", false, "browse and copy only; adding, editing and deleting are turned off")
+	captureFlag := fs.Bool("capture", false, "same as the capture subcommand: add a snippet from the clipboard and exit")
+	storage := fs.String("storage", "", "where to keep snippets: file or sqlite (default from config)")
+	fs.Parse(args)
+
+	initialModel, err := initialModel(*collection, *storage, *readOnly)
 	if err != nil {
-		fmt.Println("Error initializing model:", err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
 	}
+	switch {
+	case capture || *captureFlag:
+		initialModel = initialModel.startCapture()
+	case *pick:
+		initialModel.pick = true
+		initialModel.state = "view"
+	}
 
 	p := tea.NewProgram(initialModel, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
@@ -329,52 +2640,110 @@ func main() {
 	}
 }
 
-func loadSnippets() []snippet {
-	file, err := os.Open(snippetsFile)
-	if err != nil {
-		return []snippet{}
+// unionSnippets combines the snippets on disk with ours. Ours win where
+// both are the same snippet, going by UID when both have one and by ID
+// otherwise; snippets only one side has are kept. One of ours whose ID
+// was taken on disk by a different snippet gets a new ID from newID.
+func unionSnippets(disk, ours []snippet, newID func() int) []snippet {
+	merged := append([]snippet(nil), disk...)
+	for _, s := range ours {
+		replaced := false
+		for i := range merged {
+			if sameSnippet(merged[i], s) {
+				merged[i] = s
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			if slices.ContainsFunc(merged, func(d snippet) bool { return d.ID == s.ID }) {
+				s.ID = newID()
+			}
+			merged = append(merged, s)
+		}
 	}
-	defer file.Close()
+	return merged
+}
 
-	var snippets []snippet
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		parts := strings.Split(scanner.Text(), "|||")
-		if len(parts) == 4 {
-			id, _ := strconv.Atoi(parts[0])
-			decodedCode, _ := base64.StdEncoding.DecodeString(parts[3])
-			snippets = append(snippets, snippet{
-				ID:       id,
-				Name:     parts[1],
-				Language: parts[2],
-				Code:     string(decodedCode),
-			})
+// sameSnippet reports whether a and b are copies of one snippet: by UID
+// if both have one, or else by ID.
+func sameSnippet(a, b snippet) bool {
+	if a.UID != "" && b.UID != "" {
+		return a.UID == b.UID
+	}
+	return a.ID == b.ID
+}
+
+// mergeSnippets appends the code and tags of the snippet with secondaryID to
+// the one with primaryID, keeps the primary's name and language, and drops
+// the secondary.
+func mergeSnippets(snippets []snippet, primaryID, secondaryID int) []snippet {
+	var primary, secondary *snippet
+	for i := range snippets {
+		switch snippets[i].ID {
+		case primaryID:
+			primary = &snippets[i]
+		case secondaryID:
+			secondary = &snippets[i]
 		}
 	}
-	return snippets
+	if primary == nil || secondary == nil {
+		return snippets
+	}
+
+	separator := commentLine(primary.Language, "merged from "+secondary.Name)
+	primary.Code = strings.TrimRight(primary.Code, "\n") + "\n\n" + separator + "\n" + secondary.Code
+	primary.Blocks = append(primary.Blocks, secondary.Blocks...)
+	for _, t := range secondary.Tags {
+		if !containsTag(primary.Tags, t) {
+			primary.Tags = append(primary.Tags, t)
+		}
+	}
+
+	return removeSnippet(snippets, secondaryID)
 }
 
-func saveSnippets(snippets []snippet) {
-	file, err := os.Create(snippetsFile)
-	if err != nil {
-		fmt.Println("Error saving snippets:", err)
-		return
+// sampleSnippet is offered on the welcome screen so the lists aren't empty
+// the first time round.
+func sampleSnippet(id int) snippet {
+	return snippet{
+		ID:        id,
+		Name:      "Find large files",
+		Language:  "sh",
+		Code:      "find . -type f -size +100M -exec ls -lh {} \\;",
+		Tags:      []string{"sample", "shell"},
+		CreatedAt: time.Now(),
+	}
+}
+
+// longestLine returns the 1-based number and length in characters of the
+// longest line in text.
+func longestLine(text string) (int, int) {
+	best, bestLen := 0, 0
+	for i, line := range strings.Split(text, "\n") {
+		if n := utf8.RuneCountInString(line); n > bestLen {
+			best, bestLen = i+1, n
+		}
 	}
-	defer file.Close()
+	return best, bestLen
+}
 
-	for _, s := range snippets {
-		// Encode the code as base64 to preserve newlines
-		encodedCode := base64.StdEncoding.EncodeToString([]byte(s.Code))
-		fmt.Fprintf(file, "%d|||%s|||%s|||%s\n", s.ID, s.Name, s.Language, encodedCode)
+// appendNote adds text to notes as a new line stamped with at.
+func appendNote(notes, text string, at time.Time) string {
+	line := at.Format("2006-01-02 15:04") + "  " + strings.TrimSpace(text)
+	if notes == "" {
+		return line
 	}
+	return strings.TrimRight(notes, "\n") + "\n" + line
 }
 
-func generateID(snippets []snippet) int {
-	maxID := 0
+// removeSnippet returns snippets without the one with the given ID.
+func removeSnippet(snippets []snippet, id int) []snippet {
+	kept := snippets[:0]
 	for _, s := range snippets {
-		if s.ID > maxID {
-			maxID = s.ID
+		if s.ID != id {
+			kept = append(kept, s)
 		}
 	}
-	return maxID + 1
+	return kept
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// testModel starts the TUI in an empty temp directory, so nothing is read
+// from or written to the real snippets file, and gives it snippets and a
+// terminal of the given size.
+func testModel(t *testing.T, width, height int, snippets []snippet) model {
+	t.Helper()
+	inTempDir(t)
+	m, err := initialModel(defaultCollection, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if load := m.Init(); load != nil {
+		next, _ := m.Update(load())
+		m = next.(model)
+	}
+	m.snippets = snippets
+	m.index.sync(m.snippets)
+	next, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: height})
+	return next.(model)
+}
+
+// inTempDir runs the rest of the test in an empty temp directory, with
+// its own empty data directory for named collections.
+func inTempDir(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// send presses keys in m one after another, named as tea.KeyMsg.String
+// names them, and returns the model they leave.
+func send(m model, keys ...string) model {
+	for _, k := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "ctrl+s":
+			msg = tea.KeyMsg{Type: tea.KeyCtrlS}
+		}
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// isMarkdown reports whether the detail view renders s with glamour
+// rather than showing its code as is.
+func isMarkdown(s snippet) bool {
+	lang := strings.ToLower(strings.TrimSpace(s.Language))
+	return lang == "markdown" || lang == "md"
+}
+
+// markdownShown reports whether the detail view is rendering s rather
+// than showing its raw code.
+func (m model) markdownShown(s snippet) bool {
+	return isMarkdown(s) && !m.rawMarkdown
+}
+
+// markdownWidth is what rendered Markdown wraps at, following the terminal.
+func (m model) markdownWidth() int {
+	if m.width == 0 {
+		// No size from the terminal yet
+		return 80
+	}
+	return max(m.width-4, 20)
+}
+
+// markdownLines renders s as Markdown at the terminal width. If glamour
+// fails the code is shown as is, so the snippet is still readable.
+func (m model) markdownLines(s snippet) []string {
+	width := m.markdownWidth()
+	key := renderKey{id: s.ID, markdown: true, width: width}
+	return m.render.lines(key, s.Code, func(code string) []string {
+		style := "light"
+		if lipgloss.HasDarkBackground() {
+			style = "dark"
+		}
+		r, err := glamour.NewTermRenderer(glamour.WithStandardStyle(style), glamour.WithWordWrap(width))
+		if err != nil {
+			return strings.Split(code, "\n")
+		}
+		out, err := r.Render(code)
+		if err != nil {
+			return strings.Split(code, "\n")
+		}
+		return strings.Split(strings.Trim(out, "\n"), "\n")
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pagerDoneMsg reports that the pager exited and we are back in the TUI.
+type pagerDoneMsg struct{ err error }
+
+// pagerCommand returns $PAGER split into a command and its arguments, or
+// nil if it is unset or names a program that can't be found, in which
+// case the built in pager is used.
+func pagerCommand() []string {
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil
+	}
+	return args
+}
+
+// openInPager hands the terminal to the pager in args with code on its
+// stdin, and resumes the TUI once it exits.
+func openInPager(args []string, code string) tea.Cmd {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(code)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return pagerDoneMsg{err: err}
+	})
+}
+
+// page opens s in $PAGER, or in the built in pager screen without one,
+// unless it is sensitive and hasn't been revealed.
+func (m *model) page(s snippet) tea.Cmd {
+	if s.Sensitive && !m.revealed {
+		m.status = "Reveal it with r before opening it in the pager"
+		return nil
+	}
+	if args := pagerCommand(); args != nil {
+		return openInPager(args, s.Code)
+	}
+	m.navigate("pager")
+	m.pagerName = s.Name
+	width, height := m.pagerSize()
+	m.viewport = viewport.New(width, height)
+	m.viewport.SetContent(strings.Join(m.renderedLines(s), "\n"))
+	return nil
+}
+
+// pagerSize leaves room around the built in pager for its title and key
+// hints.
+func (m model) pagerSize() (int, int) {
+	if m.width == 0 {
+		// No size from the terminal yet
+		return 80, 20
+	}
+	return m.width, max(m.height-6, 3)
+}
+
+func (m model) pagerView() string {
+	var s strings.Builder
+	s.WriteString(m.renderTitle(fmt.Sprintf("%s  %3.f%%", m.pagerName, m.viewport.ScrollPercent()*100)))
+	s.WriteString("\n\n")
+	s.WriteString(m.viewport.View() + "\n")
+	s.WriteString(m.keyHints())
+	return s.String()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/sahilm/fuzzy"
+)
+
+// paletteAction is one entry of the Ctrl+K command palette.
+type paletteAction struct {
+	Name string
+	Desc string
+	run  func(m model) (tea.Model, tea.Cmd)
+}
+
+// paletteActions lists everything the palette can do. The menu items come
+// first so the palette is a superset of the menu.
+func paletteActions() []paletteAction {
+	actions := []paletteAction{
+		{Name: "View Snippets", Desc: "browse, open and copy snippets", run: menuAction("View Snippets")},
+		{Name: "Add Snippet", Desc: "save a new snippet", run: menuAction("Add Snippet")},
+		{Name: "Browse Tags", Desc: "filter, rename or delete tags", run: menuAction("Browse Tags")},
+		{Name: "Browse Languages", Desc: "languages by count, open one to view its snippets", run: menuAction("Browse Languages")},
+		{Name: "Delete Snippet", Desc: "delete, rename, pin or merge snippets", run: menuAction("Delete Snippet")},
+		{Name: "Switch Collection", Desc: "open another collection", run: menuAction("Switch Collection")},
+		{Name: "Library Info", Desc: "snippet count, size and file path", run: menuAction("Library Info")},
+		{Name: "Insights", Desc: "the commands shell snippets use most", run: menuAction("Insights")},
+		{Name: "Stale Snippets", Desc: "snippets not copied in a while, to prune", run: menuAction("Stale Snippets")},
+		{Name: "New Collection", Desc: "create a collection and open it", run: func(m model) (tea.Model, tea.Cmd) {
+			if m.readOnly {
+				m.status = readOnlyStatus
+				return m, nil
+			}
+			m.navigate("newcollection")
+			m.input.Placeholder = "Collection name"
+			m.input.SetValue("")
+			m.input.Focus()
+			return m, nil
+		}},
+		{Name: "Filter by Date", Desc: "show snippets added in a date range", run: func(m model) (tea.Model, tea.Cmd) {
+			m.navigate("view")
+			m.selectedItem = 0
+			return m.openDateFilter(), nil
+		}},
+		{Name: "Search Snippets", Desc: "find snippets by name, tag or code", run: func(m model) (tea.Model, tea.Cmd) {
+			m.navigate("view")
+			m.selectedItem = 0
+			m.searching = true
+			return m, m.search.Focus()
+		}},
+		{Name: "Save Now", Desc: "write pending changes to disk", run: func(m model) (tea.Model, tea.Cmd) {
+			return m, m.startSave()
+		}},
+		{Name: "Next Theme", Desc: "switch to the next color theme", run: func(m model) (tea.Model, tea.Cmd) {
+			m.cycleTheme()
+			return m, nil
+		}},
+		{Name: "Show All Keys", Desc: "toggle the full key hints in footers", run: func(m model) (tea.Model, tea.Cmd) {
+			m.allKeys = !m.allKeys
+			return m, nil
+		}},
+	}
+	return append(actions, paletteAction{Name: "Quit", Desc: "save and exit", run: menuAction("Quit")})
+}
+
+func menuAction(name string) func(m model) (tea.Model, tea.Cmd) {
+	return func(m model) (tea.Model, tea.Cmd) {
+		return m.openMenuItem(name)
+	}
+}
+
+// filterPalette fuzzy matches query against the action names, best first.
+// An empty query keeps every action in order.
+func filterPalette(query string, actions []paletteAction) []paletteAction {
+	if query == "" {
+		return actions
+	}
+	names := make([]string, len(actions))
+	for i, a := range actions {
+		names[i] = a.Name
+	}
+	var matched []paletteAction
+	for _, match := range fuzzy.Find(query, names) {
+		matched = append(matched, actions[match.Index])
+	}
+	return matched
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// readOnlyStatus is shown when --read-only turns an action down.
+const readOnlyStatus = "Read-only: snippets can't be changed"
+
+// readOnlyItems are the menu entries that only lead to changes, which
+// --read-only greys out.
+var readOnlyItems = map[string]bool{"Add Snippet": true, "Delete Snippet": true}
+
+// changesSnippets reports whether msg would add, edit or delete snippets
+// on the current screen, for --read-only to turn it down. Copying,
+// browsing, searching and display toggles are left alone.
+func (m model) changesSnippets(msg tea.KeyMsg) bool {
+	switch m.state {
+	case "view":
+		return key.Matches(msg, keys.TagSnippet, keys.Pin, keys.Archive, keys.Sensitive)
+	case "detail":
+		return (m.findQuery == "" && key.Matches(msg, keys.AddNote)) || key.Matches(msg, keys.AddBlock, keys.RemoveBlock, keys.Rate, keys.EditSource)
+	case "delete":
+		return key.Matches(msg, keys.Delete, keys.Rename, keys.Pin, keys.Archive, keys.Merge)
+	case "tags":
+		return key.Matches(msg, keys.Rename, keys.DeleteTag)
+	case "stale":
+		// Enter deletes here
+		return key.Matches(msg, keys.Open, keys.Archive)
+	case "collections":
+		return key.Matches(msg, keys.NewCollection)
+	}
+	return false
+}
+
+// menuDelegate draws the main menu with the selected entry in
+// selectedItemStyle's color, like every other list, and dims
+// readOnlyItems in read-only mode.
+type menuDelegate struct {
+	list.DefaultDelegate
+	readOnly bool
+}
+
+func (d menuDelegate) Render(w io.Writer, m list.Model, index int, it list.Item) {
+	accent := selectedItemStyle.GetForeground()
+	d.Styles.SelectedTitle = d.Styles.SelectedTitle.Foreground(accent).BorderForeground(accent).Bold(true)
+	if i, ok := it.(item); ok && d.readOnly && readOnlyItems[string(i)] {
+		dimmed := d.Styles.DimmedTitle.GetForeground()
+		d.Styles.NormalTitle = d.Styles.DimmedTitle
+		d.Styles.SelectedTitle = d.Styles.SelectedTitle.Foreground(dimmed).BorderForeground(dimmed)
+	}
+	d.DefaultDelegate.Render(w, m, index, it)
+}
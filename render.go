@@ -0,0 +1,62 @@
+package main
+
+// renderCache keeps the display lines of each snippet's code, so screens
+// that redraw on every key don't redo the work for big snippets. An entry
+// is only reused while the code and the display options it was built with
+// are the same, so edits and toggles never show stale lines. Each snippet
+// has one slot for its code lines and one for its rendered Markdown, and a
+// rebuild replaces what was there, so the cache never holds more than two
+// entries per snippet however often the options or the width change.
+type renderCache struct {
+	entries map[renderSlot]renderedCode
+}
+
+// renderSlot is where a snippet's entry of one kind is kept.
+type renderSlot struct {
+	id       int
+	markdown bool
+}
+
+// renderKey is everything besides the code that changes how it is drawn.
+// Rendered Markdown depends on the width it was wrapped at.
+type renderKey struct {
+	id          int
+	whitespace  bool
+	lineNumbers bool
+	markdown    bool
+	width       int
+}
+
+type renderedCode struct {
+	key   renderKey
+	code  string
+	lines []string
+}
+
+func newRenderCache() *renderCache {
+	return &renderCache{entries: map[renderSlot]renderedCode{}}
+}
+
+// lines returns the cached lines for key if they were built from code, or
+// builds them with render and keeps them in place of the slot's old ones.
+func (c *renderCache) lines(key renderKey, code string, render func(string) []string) []string {
+	slot := renderSlot{id: key.id, markdown: key.markdown}
+	if e, ok := c.entries[slot]; ok && e.key == key && e.code == code {
+		return e.lines
+	}
+	lines := render(code)
+	c.entries[slot] = renderedCode{key: key, code: code, lines: lines}
+	return lines
+}
+
+// forget drops the entries of the snippet with the given ID.
+func (c *renderCache) forget(id int) {
+	delete(c.entries, renderSlot{id: id})
+	delete(c.entries, renderSlot{id: id, markdown: true})
+}
+
+// clear drops every entry, for when something outside the key changes
+// how code is drawn.
+func (c *renderCache) clear() {
+	clear(c.entries)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runTimeout is how long a run snippet gets before it is killed, so a
+// command that waits forever can't leave the output screen hanging.
+const runTimeout = time.Minute
+
+// runInterpreters are the languages snippets can be run in, with the
+// program that runs them. Only shells are here: their code runs as it is
+// written, where most languages would need a file and a build.
+var runInterpreters = map[string]string{
+	"sh":    "sh",
+	"shell": "sh",
+	"bash":  "bash",
+	"zsh":   "zsh",
+}
+
+// runDoneMsg carries what a run snippet printed once it has exited.
+type runDoneMsg struct {
+	name   string
+	output string
+	err    error
+}
+
+// runCode runs code with interp in the background, with nothing on its
+// stdin, and reports back with everything it wrote.
+func runCode(name, interp, code string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, interp, "-c", code).CombinedOutput()
+		if ctx.Err() != nil {
+			err = fmt.Errorf("stopped after %s", runTimeout)
+		}
+		return runDoneMsg{name: name, output: string(out), err: err}
+	}
+}
+
+// runnable returns the program that runs lang, or why it can't be run:
+// either there is no interpreter for it or runLanguages doesn't allow it.
+func (m model) runnable(lang string) (string, error) {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	interp, ok := runInterpreters[lang]
+	switch {
+	case !ok && lang == "":
+		return "", fmt.Errorf("snippets with no language can't be run, only shell ones")
+	case !ok:
+		return "", fmt.Errorf("%s snippets can't be run, only shell ones", lang)
+	}
+	if !slices.Contains(m.cfg.RunLanguages, lang) {
+		return "", fmt.Errorf("running %s snippets is off; add %q to runLanguages in %s", lang, lang, configFile)
+	}
+	return interp, nil
+}
+
+// copyAndRun copies s's code and runs it, asking for any placeholders
+// first and then whether to go ahead.
+func (m model) copyAndRun(id int, s snippet) (tea.Model, tea.Cmd) {
+	if s.Sensitive && !m.revealed {
+		m.status = "Reveal it with r before running it"
+		return m, nil
+	}
+	if _, err := m.runnable(s.Language); err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+	names := placeholders(s.Code)
+	if len(names) == 0 {
+		return m.confirmRun(id, s.Name, s.Language, s.Code)
+	}
+	m.fill = &templateFill{id: id, code: s.Code, names: names, values: map[string]string{}, run: s.Language}
+	m.navigate("fill")
+	m.input.Placeholder = names[0]
+	m.input.SetValue("")
+	return m, m.input.Focus()
+}
+
+// confirmRun asks before copying code and running it as lang. The copy
+// happens whether or not the run succeeds.
+func (m model) confirmRun(id int, name, lang, code string) (tea.Model, tea.Cmd) {
+	interp, err := m.runnable(lang)
+	if err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+	return m.ask(confirmation{
+		prompt: fmt.Sprintf("Copy and run %q with %s?", name, interp),
+		onYes: func(m model) (tea.Model, tea.Cmd) {
+			if err := copyToClipboard(code); err != nil {
+				m.err = fmt.Errorf("copy failed: %w", err)
+				return m, nil
+			}
+			cmd := m.markUsed(id)
+			m.status = fmt.Sprintf("Copied %q; running it", name)
+			return m, tea.Batch(cmd, runCode(name, interp, code))
+		},
+	})
+}
+
+// showRunOutput opens the built in pager on what a run printed, titled
+// with how it ended.
+func (m model) showRunOutput(msg runDoneMsg) model {
+	outcome := "copied, ran fine"
+	if msg.err != nil {
+		outcome = "copied, " + msg.err.Error()
+	}
+	output := msg.output
+	if output == "" {
+		output = "(no output)"
+	}
+	m.navigate("pager")
+	m.pagerName = fmt.Sprintf("%s (%s)", msg.name, outcome)
+	width, height := m.pagerSize()
+	m.viewport = viewport.New(width, height)
+	m.viewport.SetContent(strings.TrimRight(output, "\n"))
+	m.status = ""
+	return m
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// finishSave runs cmd and hands m the savedMsg it comes back with, if any.
+func finishSave(t *testing.T, m model, cmd tea.Cmd) model {
+	t.Helper()
+	if cmd == nil {
+		return m
+	}
+	msgs := []tea.Msg{cmd()}
+	if batch, ok := msgs[0].(tea.BatchMsg); ok {
+		msgs = nil
+		for _, c := range batch {
+			if c != nil {
+				msgs = append(msgs, c())
+			}
+		}
+	}
+	for _, msg := range msgs {
+		if saved, ok := msg.(savedMsg); ok {
+			next, _ := m.Update(saved)
+			m = next.(model)
+		}
+	}
+	return m
+}
+
+func TestManualSaveUseIsNotAnEdit(t *testing.T) {
+	m := testModel(t, 80, 40, []snippet{{ID: 1, UID: "a", Name: "deploy", Language: "sh", Code: "make deploy"}})
+	m.cfg.SaveMode = saveModeManual
+
+	m = finishSave(t, m, m.markUsed(1))
+	if m.dirty {
+		t.Error("copying a snippet left unsaved changes")
+	}
+	saved, err := store.Load(snippetsFile)
+	if err != nil || len(saved) != 1 || saved[0].LastUsedAt.IsZero() {
+		t.Errorf("last used time wasn't written: %+v, %v", saved, err)
+	}
+	next, cmd := m.quit()
+	if next.(model).confirm != nil || cmd == nil {
+		t.Fatal("quitting after only copying asked about unsaved changes")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("quitting after only copying didn't quit")
+	}
+
+	// With a real edit unsaved, the use waits for it rather than saving
+	// the edit behind the user's back
+	m.snippets[0].Name = "deploy prod"
+	m.persist()
+	if cmd := m.markUsed(1); cmd != nil {
+		t.Error("use saved an unsaved edit along with it")
+	}
+	if saved, _ := store.Load(snippetsFile); saved[0].Name != "deploy" {
+		t.Errorf("edit was saved without asking: %q", saved[0].Name)
+	}
+	if next, _ := m.quit(); next.(model).confirm == nil {
+		t.Error("quitting with an unsaved edit didn't ask")
+	}
+}
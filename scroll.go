@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// scrollStart is the first of total lines to show in a window of room
+// lines so that lines from up to to stay in it, centered where the ends
+// allow it. A span taller than the window starts at its top.
+func scrollStart(total, from, to, room int) int {
+	if total <= room {
+		return 0
+	}
+	start := from - (room-(to-from))/2
+	if to-from > room {
+		start = from
+	}
+	return max(0, min(start, total-room))
+}
+
+// bodyRoom is how many lines are left between a screen's header and its
+// tail once the footer and any error under them are counted, or 0 if the
+// terminal size isn't known yet. The header ends in a newline; the tail
+// doesn't have to.
+func (m model) bodyRoom(header, tail string) int {
+	if m.height == 0 {
+		return 0
+	}
+	used := strings.Count(header, "\n") + lipgloss.Height(tail) + 1
+	if m.err != nil {
+		used += lipgloss.Height(errorStyle.Render("Error: " + m.err.Error()))
+	}
+	return max(m.height-used, 3)
+}
+
+// windowLines cuts lines down to room of them around lines from up to
+// to, with a marker in place of the first or last line when there is more
+// above or below. A room of 0, or enough to fit everything, leaves them
+// whole.
+func windowLines(lines []string, from, to, room int) []string {
+	if room == 0 || len(lines) <= room {
+		return lines
+	}
+	above := placeholderStyle.PaddingLeft(4).Render("↑ more above")
+	below := placeholderStyle.PaddingLeft(4).Render("↓ more below")
+	// Leave room for both markers, then give a line back to whichever
+	// end turns out not to need one
+	start := scrollStart(len(lines), from, to, room-2)
+	switch {
+	case start == 0:
+		return append(append([]string(nil), lines[:room-1]...), below)
+	case start+room-2 >= len(lines):
+		return append([]string{above}, lines[len(lines)-room+1:]...)
+	}
+	shown := append([]string{above}, lines[start:start+room-2]...)
+	return append(shown, below)
+}
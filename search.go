@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// searchIndex is an inverted index from the words in each snippet to the
+// snippets containing them, so searching scans the vocabulary rather than
+// every code body. sync keeps it up to date by re-indexing only snippets
+// whose text changed.
+type searchIndex struct {
+	postings map[string]map[int]bool
+	docs     map[int]indexedDoc
+}
+
+// indexedDoc is what the index holds for one snippet: the text it was
+// built from, to spot changes, and the words that text produced.
+type indexedDoc struct {
+	text  string
+	words []string
+}
+
+func newSearchIndex(snippets []snippet) *searchIndex {
+	idx := &searchIndex{postings: map[string]map[int]bool{}, docs: map[int]indexedDoc{}}
+	idx.sync(snippets)
+	return idx
+}
+
+// searchText is everything about s that search looks at.
+func searchText(s snippet) string {
+	return strings.Join([]string{s.Name, s.Language, strings.Join(s.Tags, " "), s.Code}, "\n")
+}
+
+// searchWords splits text into lowercase words of letters, digits and
+// underscores.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// sync brings the index in line with snippets, indexing new and changed
+// ones and dropping those that are gone.
+func (idx *searchIndex) sync(snippets []snippet) {
+	seen := make(map[int]bool, len(snippets))
+	for _, s := range snippets {
+		seen[s.ID] = true
+		text := searchText(s)
+		if doc, ok := idx.docs[s.ID]; ok && doc.text == text {
+			continue
+		}
+		idx.remove(s.ID)
+		idx.add(s.ID, text)
+	}
+	for id := range idx.docs {
+		if !seen[id] {
+			idx.remove(id)
+		}
+	}
+}
+
+func (idx *searchIndex) add(id int, text string) {
+	words := searchWords(text)
+	for _, w := range words {
+		if idx.postings[w] == nil {
+			idx.postings[w] = map[int]bool{}
+		}
+		idx.postings[w][id] = true
+	}
+	idx.docs[id] = indexedDoc{text: text, words: words}
+}
+
+func (idx *searchIndex) remove(id int) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, w := range doc.words {
+		delete(idx.postings[w], id)
+		if len(idx.postings[w]) == 0 {
+			delete(idx.postings, w)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// match returns the IDs of snippets where every word of query appears
+// inside some indexed word, so "conf" finds "config". A blank query
+// returns nil, which callers take as no search at all; one made only of
+// punctuation, like "--" or "|", has no words and is looked for as typed
+// instead.
+func (idx *searchIndex) match(query string) map[int]bool {
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+	words := searchWords(query)
+	if len(words) == 0 {
+		return idx.scan(query)
+	}
+	var result map[int]bool
+	for _, q := range words {
+		hits := map[int]bool{}
+		for w, ids := range idx.postings {
+			if !strings.Contains(w, q) {
+				continue
+			}
+			for id := range ids {
+				if result == nil || result[id] {
+					hits[id] = true
+				}
+			}
+		}
+		result = hits
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+// scan returns the IDs of snippets whose text contains query, ignoring
+// case. It reads every indexed snippet, so match only falls back to it for
+// queries the index has no words for.
+func (idx *searchIndex) scan(query string) map[int]bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	result := map[int]bool{}
+	for id, doc := range idx.docs {
+		if strings.Contains(strings.ToLower(doc.text), query) {
+			result[id] = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// benchSnippets makes n snippets of made up code from a fixed seed, big
+// enough that searching them takes measurable time.
+func benchSnippets(n int) []snippet {
+	r := rand.New(rand.NewSource(1))
+	words := []string{"kubectl", "apply", "docker", "compose", "config", "select", "from", "where", "git", "rebase", "curl", "json", "func", "return", "error", "deploy", "backup", "ssh", "grep", "awk"}
+	snippets := make([]snippet, n)
+	for i := range snippets {
+		var code strings.Builder
+		for line := 0; line < 20; line++ {
+			for w := 0; w < 8; w++ {
+				code.WriteString(words[r.Intn(len(words))])
+				code.WriteString(fmt.Sprintf("%d ", r.Intn(500)))
+			}
+			code.WriteString("\n")
+		}
+		snippets[i] = snippet{
+			ID:       i + 1,
+			Name:     fmt.Sprintf("%s %s %d", words[r.Intn(len(words))], words[r.Intn(len(words))], i),
+			Language: "sh",
+			Tags:     []string{words[r.Intn(len(words))]},
+			Code:     code.String(),
+		}
+	}
+	return snippets
+}
+
+// linearMatch is the search the index replaced: every query word looked
+// for in the whole text of every snippet.
+func linearMatch(snippets []snippet, query string) map[int]bool {
+	words := searchWords(query)
+	result := map[int]bool{}
+	for _, s := range snippets {
+		text := strings.ToLower(searchText(s))
+		found := true
+		for _, q := range words {
+			if !strings.Contains(text, q) {
+				found = false
+				break
+			}
+		}
+		if found {
+			result[s.ID] = true
+		}
+	}
+	return result
+}
+
+var benchQueries = []string{"kubectl", "conf 42", "deploy backup ssh", "nomatch"}
+
+func TestIndexMatchesLinearScan(t *testing.T) {
+	snippets := benchSnippets(200)
+	idx := newSearchIndex(snippets)
+	for _, q := range append(benchQueries, "DOCKER", "select7") {
+		got := idx.match(q)
+		if got == nil {
+			got = map[int]bool{}
+		}
+		if want := linearMatch(snippets, q); !reflect.DeepEqual(got, want) {
+			t.Errorf("match(%q) found %d snippets, a linear scan %d", q, len(got), len(want))
+		}
+	}
+}
+
+func TestMatchPunctuation(t *testing.T) {
+	snippets := []snippet{
+		{ID: 1, Name: "flags", Code: "ls --all"},
+		{ID: 2, Name: "pipe", Code: "ps | grep x"},
+		{ID: 3, Name: "plain", Code: "echo hi"},
+	}
+	idx := newSearchIndex(snippets)
+	tests := []struct {
+		query string
+		want  map[int]bool
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"--", map[int]bool{1: true}},
+		{"|", map[int]bool{2: true}},
+		{"%%", map[int]bool{}},
+	}
+	for _, tt := range tests {
+		if got := idx.match(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("match(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkIndexMatch(b *testing.B) {
+	idx := newSearchIndex(benchSnippets(2000))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.match(benchQueries[i%len(benchQueries)])
+	}
+}
+
+func BenchmarkLinearScan(b *testing.B) {
+	snippets := benchSnippets(2000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearMatch(snippets, benchQueries[i%len(benchQueries)])
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// seedSnippets is the example library seed fills a collection with, for
+// demos and for seeing what a full list looks like. IDs count up from
+// next.
+func seedSnippets(next int) []snippet {
+	now := time.Now()
+	seeds := []snippet{
+		sampleSnippet(0),
+		{Name: "Undo last commit", Language: "sh", Code: "git reset --soft HEAD~1", Tags: []string{"git"}},
+		{Name: "Prune merged branches", Language: "sh", Code: "git branch --merged | grep -v '\\*' | xargs -n 1 git branch -d", Tags: []string{"git", "cleanup"}},
+		{Name: "Serve this directory", Language: "sh", Code: "python3 -m http.server 8000", Tags: []string{"shell", "web"}},
+		{Name: "Table sizes", Language: "sql", Code: "SELECT relname, pg_size_pretty(pg_total_relation_size(relid))\nFROM pg_catalog.pg_statio_user_tables\nORDER BY pg_total_relation_size(relid) DESC;", Tags: []string{"postgres"}},
+		{Name: "HTTP handler", Language: "go", Code: "http.HandleFunc(\"/health\", func(w http.ResponseWriter, r *http.Request) {\n\tw.WriteHeader(http.StatusOK)\n\tfmt.Fprintln(w, \"ok\")\n})", Tags: []string{"web"}},
+		{Name: "Read JSON file", Language: "python", Code: "import json\n\nwith open(\"data.json\") as f:\n    data = json.load(f)", Tags: []string{"json"}},
+		{Name: "Debounce", Language: "javascript", Code: "function debounce(fn, ms) {\n  let t;\n  return (...args) => {\n    clearTimeout(t);\n    t = setTimeout(() => fn(...args), ms);\n  };\n}", Tags: []string{"web"}},
+		{Name: "Restart a deployment", Language: "sh", Code: "kubectl rollout restart deployment/{{name}} -n {{namespace}}", Tags: []string{"kubernetes"}},
+	}
+	for i := range seeds {
+		seeds[i].ID = next + i
+		seeds[i].CreatedAt = now
+		seeds[i].UID = store.NewUID()
+	}
+	return seeds
+}
+
+// runSeed fills a collection with seedSnippets. A collection that already
+// has snippets is left alone unless --force replaces them.
+func runSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	force := fs.Bool("force", false, "replace the collection's snippets if it has any")
+	collection := fs.String("collection", "", "collection to fill (default the default collection)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	backend, err := openStore(*collection, cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer closeStore(backend)
+	snippets, version, err := backend.Load()
+	if err != nil {
+		return err
+	}
+	if len(snippets) > 0 && !*force {
+		return fmt.Errorf("%s already has %d snippets; pass --force to replace them", backend.Location(), len(snippets))
+	}
+	seeds := seedSnippets(max(backend.NextID(), store.NextID(snippets)))
+	if _, err := backend.Save(seeds, version); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Added %d example snippets to %s\n", len(seeds), backend.Location())
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// pageTemplate renders the whole collection on one page. Highlighting is
+// left to highlight.js in the browser so the server stays dependency free.
+var pageTemplate = template.Must(template.New("page").Funcs(template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>SnipSnap</title>
+<link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/highlight.js/11.9.0/styles/github.min.css">
+<script src="https://cdnjs.cloudflare.com/ajax/libs/highlight.js/11.9.0/highlight.min.js"></script>
+<script>hljs.highlightAll();</script>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; color: #222; }
+h2 { margin-bottom: 0.2em; }
+.meta { color: #777; font-size: 0.9em; }
+pre { border: 1px solid #ddd; border-radius: 4px; }
+</style>
+</head>
+<body>
+<h1>Snippets</h1>
+{{range .}}
+<section id="{{.ID}}">
+<h2><a href="#{{.ID}}">{{.Name}}</a></h2>
+<div class="meta">{{.Language}}{{if .Tags}} · {{join .Tags ", "}}{{end}}{{if not .Sensitive}} · <a href="/raw/{{.ID}}">raw</a>{{end}}</div>
+{{if .Sensitive}}<p class="meta">Hidden, this snippet is marked sensitive.</p>{{else}}<pre><code class="language-{{lower .Language}}">{{.Code}}</code></pre>{{end}}
+</section>
+{{else}}
+<p>No snippets yet.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// serve starts a read only HTTP server over the snippets in backend. They
+// are reloaded on every request so edits made in the TUI show up straight
+// away.
+func serve(addr string, backend store.Store) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		snippets, _, err := backend.Load()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := pageTemplate.Execute(w, orderForDisplay(snippets, sortAdded)); err != nil {
+			log.Println("render:", err)
+		}
+	})
+	mux.HandleFunc("GET /raw/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "bad snippet id", http.StatusBadRequest)
+			return
+		}
+		s, err := getSnippet(backend, id)
+		if errors.Is(err, store.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if s.Sensitive {
+			http.Error(w, "snippet is marked sensitive", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(s.Code))
+	})
+
+	log.Printf("Serving %s on %s", backend.Location(), addr)
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Values accepted for config.SortMode.
+const (
+	sortAdded    = "added"
+	sortName     = "name"
+	sortLanguage = "language"
+	sortNewest   = "newest"
+	sortRecent   = "recent"
+	sortRating   = "rating"
+)
+
+// sortModes is the order 's' cycles through them in.
+var sortModes = []string{sortAdded, sortName, sortLanguage, sortNewest, sortRecent, sortRating}
+
+// nextSortMode returns the sort mode after mode.
+func nextSortMode(mode string) string {
+	for i, m := range sortModes {
+		if m == mode {
+			return sortModes[(i+1)%len(sortModes)]
+		}
+	}
+	return sortModes[0]
+}
+
+// orderForDisplay returns the order every snippet list is shown in: pinned
+// snippets first, otherwise sorted by mode, where sortAdded keeps the order
+// they were added in. It doesn't modify snippets.
+func orderForDisplay(snippets []snippet, mode string) []snippet {
+	ordered := append([]snippet(nil), snippets...)
+	switch mode {
+	case sortName:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return strings.ToLower(ordered[i].Name) < strings.ToLower(ordered[j].Name)
+		})
+	case sortLanguage:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return strings.ToLower(ordered[i].Language) < strings.ToLower(ordered[j].Language)
+		})
+	case sortNewest:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].CreatedAt.After(ordered[j].CreatedAt)
+		})
+	case sortRecent:
+		// Never used snippets have the zero time and sink to the bottom
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].LastUsedAt.After(ordered[j].LastUsedAt)
+		})
+	case sortRating:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Rating > ordered[j].Rating
+		})
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Pinned && !ordered[j].Pinned
+	})
+	return ordered
+}
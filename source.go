@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// browserCommand is how this platform opens a URL in the default browser.
+func browserCommand(url string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		return exec.Command("xdg-open", url)
+	}
+}
+
+// isURL reports whether source is something a browser can open, rather
+// than a reference like a book page.
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// openSource opens s's source in the browser without waiting for it.
+func (m *model) openSource(s snippet) {
+	switch {
+	case s.Source == "":
+		m.status = "No source recorded; add one with U"
+		return
+	case !isURL(s.Source):
+		m.status = "The source isn't a web address: " + s.Source
+		return
+	}
+	if err := browserCommand(s.Source).Start(); err != nil {
+		m.err = fmt.Errorf("couldn't open the browser: %w", err)
+		return
+	}
+	m.status = "Opened " + s.Source
+}
+
+// cleanSource keeps a typed or pasted source to the one line it is
+// stored on.
+func cleanSource(source string) string {
+	return strings.Join(strings.Fields(source), " ")
+}
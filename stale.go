@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// lastActive is when s was last copied, or added if it never has been.
+func lastActive(s snippet) time.Time {
+	if !s.LastUsedAt.IsZero() {
+		return s.LastUsedAt
+	}
+	return s.CreatedAt
+}
+
+// staleSnippets returns the snippets that haven't been used in the days
+// before now, least recently used first. Those with no dates at all come
+// first, since nothing says they were ever needed. Archived snippets are
+// already out of the way and are left out.
+func staleSnippets(snippets []snippet, days int, now time.Time) []snippet {
+	cutoff := now.AddDate(0, 0, -days)
+	var stale []snippet
+	for _, s := range snippets {
+		if !s.Archived && lastActive(s).Before(cutoff) {
+			stale = append(stale, s)
+		}
+	}
+	sort.SliceStable(stale, func(i, j int) bool {
+		return lastActive(stale[i]).Before(lastActive(stale[j]))
+	})
+	return stale
+}
+
+// describeUse says when s was last used, for the stale report.
+func describeUse(s snippet, now time.Time) string {
+	switch {
+	case !s.LastUsedAt.IsZero():
+		return fmt.Sprintf("last used %s (%s)", s.LastUsedAt.Format("2006-01-02"), daysAgo(s.LastUsedAt, now))
+	case !s.CreatedAt.IsZero():
+		return fmt.Sprintf("never used, added %s (%s)", s.CreatedAt.Format("2006-01-02"), daysAgo(s.CreatedAt, now))
+	}
+	return "never used"
+}
+
+func daysAgo(t, now time.Time) string {
+	days := int(now.Sub(t).Hours() / 24)
+	if days == 1 {
+		return "1 day ago"
+	}
+	return fmt.Sprintf("%d days ago", days)
+}
+
+// usedAgo says roughly how long before now t was, e.g. "2h ago", for the
+// badge in the detail view.
+func usedAgo(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return daysAgo(t, now)
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version identifies one saved state of a Store, so a save can tell
+// whether someone else has written since. The zero Version means nothing
+// has been saved yet.
+type Version int64
+
+// Store is somewhere snippets are kept. The TUI only talks to this, so
+// other backends can stand in for the snippets file.
+type Store interface {
+	// Load returns every snippet and the version they were read at. An
+	// empty store is not an error.
+	Load() ([]Snippet, Version, error)
+	// Save replaces the stored snippets if the store is still at version
+	// since, and returns the new version. It returns ErrChanged if
+	// someone else saved in the meantime.
+	Save(snippets []Snippet, since Version) (Version, error)
+	// Version returns the version currently stored.
+	Version() Version
+	// NextID returns the next free ID the store has recorded, which can
+	// be past every snippet in it after deletions, or 0 if it has none.
+	NextID() int
+	// Location describes where the snippets are kept, e.g. a file path.
+	Location() string
+}
+
+// Lazy is a Store that can list snippets without their code and fetch a
+// single snippet whole on demand, for callers that only need a few bodies
+// out of a big library.
+type Lazy interface {
+	Store
+	// LoadMeta is Load with every Code left empty. Corrupt isn't set
+	// either, since the code isn't looked at.
+	LoadMeta() ([]Snippet, Version, error)
+	// Get returns the snippet with the given ID, code and all. It returns
+	// ErrNotFound if there is none.
+	Get(id int) (Snippet, error)
+}
+
+// File is the default Store: the line based snippets file at Path.
+type File struct {
+	Path string
+}
+
+var _ Lazy = (*File)(nil)
+
+// NewFile returns a Store backed by the snippets file at path.
+func NewFile(path string) *File {
+	return &File{Path: path}
+}
+
+func (f *File) Load() ([]Snippet, Version, error) {
+	// Take the version first so a write during the read shows up as a
+	// conflict rather than being missed
+	version := f.Version()
+	snippets, err := Load(f.Path)
+	return snippets, version, err
+}
+
+func (f *File) Save(snippets []Snippet, since Version) (Version, error) {
+	if f.Version() != since {
+		return since, ErrChanged
+	}
+	if err := Save(f.Path, snippets); err != nil {
+		return since, err
+	}
+	return f.Version(), nil
+}
+
+// LoadMeta reads the file without decoding any code.
+func (f *File) LoadMeta() ([]Snippet, Version, error) {
+	version := f.Version()
+	file, err := os.Open(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snippet{}, version, nil
+	}
+	if err != nil {
+		return nil, version, err
+	}
+	defer file.Close()
+	snippets, err := read(file, false)
+	return snippets, version, err
+}
+
+// Get scans the file for the snippet's line and decodes only that one.
+func (f *File) Get(id int) (Snippet, error) {
+	file, err := os.Open(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Snippet{}, ErrNotFound
+	}
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer file.Close()
+	want := strconv.Itoa(id)
+	scanner := newScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if prefix, _, ok := strings.Cut(line, "|||"); !ok || strings.TrimSpace(prefix) != want {
+			continue
+		}
+		if s, ok := parseLine(line, true); ok {
+			return s, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Snippet{}, err
+	}
+	return Snippet{}, ErrNotFound
+}
+
+// Version is the file's modification time.
+func (f *File) Version() Version {
+	return fileVersion(ModTime(f.Path))
+}
+
+func (f *File) NextID() int {
+	return RecordedNextID(f.Path)
+}
+
+func (f *File) Location() string {
+	return f.Path
+}
+
+func fileVersion(t time.Time) Version {
+	if t.IsZero() {
+		return 0
+	}
+	return Version(t.UnixNano())
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSaveUnwritableDir(t *testing.T) {
+	tmp := t.TempDir()
+	// A file where the data directory should be can't be created over
+	// even by root
+	blocker := filepath.Join(tmp, "data")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	readOnly := filepath.Join(tmp, "readonly")
+	if err := os.Mkdir(readOnly, 0555); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		dir        string
+		skipAsRoot bool
+	}{
+		{"parent is a file", blocker, false},
+		{"parent is read only", filepath.Join(readOnly, "data"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.skipAsRoot && os.Geteuid() == 0 {
+				t.Skip("root can write to read only directories")
+			}
+			f := NewFile(filepath.Join(tt.dir, "snippets.txt"))
+			version, err := f.Save([]Snippet{{ID: 1, Name: "a", Code: "x"}}, f.Version())
+			if err == nil {
+				t.Fatal("Save succeeded, want an error")
+			}
+			if errors.Is(err, ErrChanged) {
+				t.Fatalf("Save returned ErrChanged, want a directory error: %v", err)
+			}
+			if want := "can't create data directory " + tt.dir; !strings.Contains(err.Error(), want) {
+				t.Errorf("error = %q, want it to contain %q", err, want)
+			}
+			if version != 0 {
+				t.Errorf("version = %d, want the one passed in", version)
+			}
+		})
+	}
+}
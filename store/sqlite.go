@@ -0,0 +1,375 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	// Pure Go driver, so snipsnap still builds without cgo
+	_ "modernc.org/sqlite"
+)
+
+// schema creates the tables a SQLite store keeps. position holds each
+// snippet's place in the list, since the file store's order is the order
+// snippets were added in. meta holds the version and next free ID.
+const schema = `
+CREATE TABLE IF NOT EXISTS snippets (
+	id        INTEGER PRIMARY KEY,
+	position  INTEGER NOT NULL,
+	name      TEXT NOT NULL,
+	language  TEXT NOT NULL,
+	code      TEXT NOT NULL,
+	tags      TEXT NOT NULL DEFAULT '',
+	pinned    INTEGER NOT NULL DEFAULT 0,
+	sensitive INTEGER NOT NULL DEFAULT 0,
+	archived  INTEGER NOT NULL DEFAULT 0,
+	created   TEXT NOT NULL DEFAULT '',
+	notes     TEXT NOT NULL DEFAULT '',
+	corrupt   INTEGER NOT NULL DEFAULT 0,
+	used      TEXT NOT NULL DEFAULT '',
+	uid       TEXT NOT NULL DEFAULT '',
+	blocks    TEXT NOT NULL DEFAULT '',
+	rating    INTEGER NOT NULL DEFAULT 0,
+	source    TEXT NOT NULL DEFAULT '',
+	badnotes  INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value INTEGER NOT NULL
+);`
+
+// SQLite is a Store kept in a SQLite database. Saving only touches the
+// rows of snippets that were added, changed or deleted, so large
+// libraries aren't rewritten on every edit.
+type SQLite struct {
+	Path string
+	db   *sql.DB
+}
+
+var _ Lazy = (*SQLite)(nil)
+
+// OpenSQLite opens the database at path, creating it and its tables if
+// needed. Close it when done.
+func OpenSQLite(path string) (*SQLite, error) {
+	if err := EnsureDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// Saves can run in the background while the TUI reads, and SQLite
+	// allows one writer at a time anyway
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set up %s: %w", path, err)
+	}
+	if err := addColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to upgrade %s: %w", path, err)
+	}
+	return &SQLite{Path: path, db: db}, nil
+}
+
+// addedColumns are the snippets columns newer than the first schema, which
+// databases made before them need adding.
+var addedColumns = []struct{ name, decl string }{
+	{"used", "TEXT NOT NULL DEFAULT ''"},
+	{"uid", "TEXT NOT NULL DEFAULT ''"},
+	{"blocks", "TEXT NOT NULL DEFAULT ''"},
+	{"rating", "INTEGER NOT NULL DEFAULT 0"},
+	{"source", "TEXT NOT NULL DEFAULT ''"},
+	{"badnotes", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+func addColumns(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('snippets')`)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		have[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, c := range addedColumns {
+		if have[c.name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE snippets ADD COLUMN ` + c.name + ` ` + c.decl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) Load() ([]Snippet, Version, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	version, err := metaValue(tx, "version")
+	if err != nil {
+		return nil, 0, err
+	}
+	snippets, err := loadRows(tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, Version(version), nil
+}
+
+// LoadMeta reads every row but leaves the code column behind.
+func (s *SQLite) LoadMeta() ([]Snippet, Version, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+	version, err := metaValue(tx, "version")
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := tx.Query(`SELECT id, name, language, '', tags, pinned, sensitive,
+		archived, created, notes, 0, used, uid, '', rating, source, badnotes FROM snippets ORDER BY position, id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	snippets, err := scanRows(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, Version(version), nil
+}
+
+func (s *SQLite) Get(id int) (Snippet, error) {
+	rows, err := s.db.Query(`SELECT id, name, language, code, tags, pinned, sensitive,
+		archived, created, notes, corrupt, used, uid, blocks, rating, source, badnotes FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return Snippet{}, err
+	}
+	snippets, err := scanRows(rows)
+	if err != nil {
+		return Snippet{}, err
+	}
+	if len(snippets) == 0 {
+		return Snippet{}, ErrNotFound
+	}
+	return snippets[0], nil
+}
+
+func (s *SQLite) Save(snippets []Snippet, since Version) (Version, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return since, err
+	}
+	defer tx.Rollback()
+
+	version, err := metaValue(tx, "version")
+	if err != nil {
+		return since, err
+	}
+	if Version(version) != since {
+		return since, ErrChanged
+	}
+	stored, err := loadRows(tx)
+	if err != nil {
+		return since, err
+	}
+	old := make(map[int]storedRow, len(stored))
+	for i, sn := range stored {
+		old[sn.ID] = storedRow{sn, i}
+	}
+
+	keep := make(map[int]bool, len(snippets))
+	for pos, sn := range snippets {
+		keep[sn.ID] = true
+		if prev, ok := old[sn.ID]; ok && prev.position == pos && sameSnippet(prev.Snippet, sn) {
+			continue
+		}
+		if err := putRow(tx, pos, sn); err != nil {
+			return since, err
+		}
+	}
+	for id := range old {
+		if !keep[id] {
+			if _, err := tx.Exec(`DELETE FROM snippets WHERE id = ?`, id); err != nil {
+				return since, err
+			}
+		}
+	}
+
+	next, err := metaValue(tx, "next")
+	if err != nil {
+		return since, err
+	}
+	if err := setMeta(tx, "next", int64(max(NextID(snippets), int(next)))); err != nil {
+		return since, err
+	}
+	if err := setMeta(tx, "version", version+1); err != nil {
+		return since, err
+	}
+	if err := tx.Commit(); err != nil {
+		return since, err
+	}
+	return Version(version + 1), nil
+}
+
+// Version is a counter bumped by every save.
+func (s *SQLite) Version() Version {
+	version, _ := metaValue(s.db, "version")
+	return Version(version)
+}
+
+func (s *SQLite) NextID() int {
+	next, _ := metaValue(s.db, "next")
+	return int(next)
+}
+
+func (s *SQLite) Location() string {
+	return s.Path
+}
+
+// MigrateFile copies the snippets file at path into s, the first time s
+// is opened. It does nothing once s has been saved to, or if there is no
+// file, and leaves the file itself alone.
+func (s *SQLite) MigrateFile(path string) error {
+	if s.Version() != 0 {
+		return nil
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	file := NewFile(path)
+	snippets, _, err := file.Load()
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	if _, err := s.Save(snippets, 0); err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	// Carry over the file's high-water mark, not just its snippets'
+	if next := file.NextID(); next > s.NextID() {
+		return setMeta(s.db, "next", int64(next))
+	}
+	return nil
+}
+
+// storedRow is a snippet as found in the database, with its position.
+type storedRow struct {
+	Snippet
+	position int
+}
+
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func metaValue(q querier, key string) (int64, error) {
+	var value int64
+	err := q.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return value, err
+}
+
+func setMeta(q querier, key string, value int64) error {
+	_, err := q.Exec(`INSERT INTO meta (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+func loadRows(q querier) ([]Snippet, error) {
+	rows, err := q.Query(`SELECT id, name, language, code, tags, pinned, sensitive,
+		archived, created, notes, corrupt, used, uid, blocks, rating, source, badnotes FROM snippets ORDER BY position, id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(rows)
+}
+
+// scanRows reads snippets from rows selected in the column order of
+// loadRows, and closes them.
+func scanRows(rows *sql.Rows) ([]Snippet, error) {
+	defer rows.Close()
+
+	snippets := []Snippet{}
+	for rows.Next() {
+		var sn Snippet
+		var tags, created, used, blocks string
+		if err := rows.Scan(&sn.ID, &sn.Name, &sn.Language, &sn.Code, &tags, &sn.Pinned,
+			&sn.Sensitive, &sn.Archived, &created, &sn.Notes, &sn.Corrupt, &used, &sn.UID, &blocks, &sn.Rating, &sn.Source, &sn.NotesCorrupt); err != nil {
+			return nil, err
+		}
+		// One stored block per line, as the file format stores them
+		if blocks != "" {
+			for _, line := range strings.Split(blocks, "\n") {
+				sn.Blocks = append(sn.Blocks, parseBlock(line))
+			}
+		}
+		sn.Tags = ParseTags(tags)
+		sn.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		sn.LastUsedAt, _ = time.Parse(time.RFC3339, used)
+		snippets = append(snippets, sn)
+	}
+	return snippets, rows.Err()
+}
+
+func putRow(q querier, position int, sn Snippet) error {
+	_, err := q.Exec(`INSERT OR REPLACE INTO snippets (id, position, name, language, code,
+		tags, pinned, sensitive, archived, created, notes, corrupt, used, uid, blocks, rating, source, badnotes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sn.ID, position, sn.Name, sn.Language, sn.Code, strings.Join(sn.Tags, ","),
+		sn.Pinned, sn.Sensitive, sn.Archived, timeText(sn.CreatedAt), sn.Notes, sn.Corrupt,
+		timeText(sn.LastUsedAt), sn.UID, blocksText(sn.Blocks), sn.Rating, sn.Source, sn.NotesCorrupt)
+	return err
+}
+
+func blocksText(blocks []Block) string {
+	lines := make([]string, len(blocks))
+	for i, b := range blocks {
+		lines[i] = formatBlock(b)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// timeText is how times are stored: RFC 3339, or empty for the zero time.
+func timeText(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// sameSnippet reports whether saving b over a would change nothing. Times
+// are compared as stored, to the second.
+func sameSnippet(a, b Snippet) bool {
+	return a.ID == b.ID && a.UID == b.UID && a.Name == b.Name && a.Language == b.Language &&
+		a.Code == b.Code && slices.Equal(a.Blocks, b.Blocks) && slices.Equal(a.Tags, b.Tags) && a.Pinned == b.Pinned &&
+		a.Sensitive == b.Sensitive && a.Archived == b.Archived && a.Rating == b.Rating &&
+		a.CreatedAt.Unix() == b.CreatedAt.Unix() && a.LastUsedAt.Unix() == b.LastUsedAt.Unix() &&
+		a.Source == b.Source && a.Notes == b.Notes && a.Corrupt == b.Corrupt && a.NotesCorrupt == b.NotesCorrupt
+}
@@ -0,0 +1,471 @@
+// Package store reads and writes SnipSnap snippet files. It is what the
+// snipsnap TUI is built on and can be used on its own to embed a snippet
+// library in other tools.
+//
+// A file holds one snippet per line as id|||name|||language|||code, with
+// the code base64 encoded so it can span lines, followed by optional
+// |||key=value metadata fields. Each extra language's code is a
+// |||block=<language>:<base64 code> field. A first line of the form
+// #snipsnap|||next=<id> records the next free ID, so IDs of deleted
+// snippets aren't handed out again.
+//
+// File and SQLite both implement Store, the interface the TUI saves
+// through.
+package store
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snippet is a single saved piece of code.
+type Snippet struct {
+	ID        int
+	Name      string
+	Language  string
+	Code      string
+	Tags      []string
+	Pinned    bool
+	Sensitive bool
+	// Archived snippets are kept but left out of the view by default.
+	Archived  bool
+	CreatedAt time.Time
+	// Rating is 0 to MaxRating stars, 0 meaning unrated.
+	Rating int
+	// LastUsedAt is when the snippet was last copied, or zero if never.
+	LastUsedAt time.Time
+	// UID is a UUID that, unlike ID, stays unique when collections from
+	// different machines are merged. Empty until AssignUIDs gives it one.
+	UID string
+	// Blocks are the snippet again in other languages, e.g. a shell
+	// command and its PowerShell equivalent. Language and Code stay the
+	// first block, so snippets with one language are as they always were.
+	Blocks []Block
+	// Source is where the snippet came from, usually a URL. It is one
+	// line.
+	Source string
+	// Notes is a running log about the snippet, one timestamped entry
+	// per line.
+	Notes string
+	// Corrupt means the stored code wasn't valid base64. Code then holds
+	// the stored text as it was, and Write puts it back unchanged so
+	// nothing is lost before it can be repaired.
+	Corrupt bool
+	// NotesCorrupt is Corrupt for the notes: Notes holds them as stored.
+	NotesCorrupt bool
+}
+
+// MaxRating is the most stars a snippet can be rated.
+const MaxRating = 5
+
+// ValidRating reports whether r is a rating a snippet can have.
+func ValidRating(r int) bool {
+	return r >= 0 && r <= MaxRating
+}
+
+// Block is the code of a snippet in one of its extra languages.
+type Block struct {
+	Language string
+	Code     string
+	// Corrupt means the stored block couldn't be decoded. Code then holds
+	// the whole stored field, language and all, and it is written back
+	// unchanged.
+	Corrupt bool
+}
+
+// AllBlocks returns every version of s's code, its own Language and Code
+// first.
+func (s Snippet) AllBlocks() []Block {
+	return append([]Block{{Language: s.Language, Code: s.Code, Corrupt: s.Corrupt}}, s.Blocks...)
+}
+
+// formatBlock encodes b as stored: its language, then its code in base64.
+// Base64 has no colons, so the last one splits them.
+func formatBlock(b Block) string {
+	if b.Corrupt {
+		return b.Code
+	}
+	return b.Language + ":" + base64.StdEncoding.EncodeToString([]byte(b.Code))
+}
+
+// parseBlock decodes a block stored by formatBlock. One that can't be
+// decoded comes back Corrupt, holding text as it was.
+func parseBlock(text string) Block {
+	i := strings.LastIndexByte(text, ':')
+	if i < 0 {
+		return Block{Code: text, Corrupt: true}
+	}
+	code, err := decodeField(text[i+1:])
+	if err != nil {
+		return Block{Code: text, Corrupt: true}
+	}
+	return Block{Language: text[:i], Code: string(code)}
+}
+
+// Damaged reports whether any of s's code or notes couldn't be decoded
+// and is being kept as stored.
+func (s Snippet) Damaged() bool {
+	if s.Corrupt || s.NotesCorrupt {
+		return true
+	}
+	return slices.ContainsFunc(s.Blocks, func(b Block) bool { return b.Corrupt })
+}
+
+// HasTags reports whether s carries every one of tags.
+func (s Snippet) HasTags(tags []string) bool {
+	for _, want := range tags {
+		if !slices.Contains(s.Tags, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasLanguage reports whether s is written in lang, ignoring case.
+func (s Snippet) HasLanguage(lang string) bool {
+	return strings.EqualFold(strings.TrimSpace(s.Language), strings.TrimSpace(lang))
+}
+
+// maxLineSize is the longest line Read accepts, which bounds the size of a
+// single snippet.
+const maxLineSize = 256 << 20
+
+// headerPrefix starts the header line, which holds file wide fields
+// rather than a snippet. It has too few ||| fields to be taken for one.
+const headerPrefix = "#snipsnap"
+
+// ErrChanged means the snippets file was written by someone else since it
+// was last loaded or saved.
+var ErrChanged = errors.New("snippets file changed on disk")
+
+// ErrNotFound means there is no snippet with the ID asked for.
+var ErrNotFound = errors.New("no such snippet")
+
+// Load reads every snippet in the file at path. A missing file is an empty
+// library, not an error.
+func Load(path string) ([]Snippet, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snippet{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read parses snippets in the file format from r. Code that fails to
+// decode doesn't fail the read; the snippet is marked Corrupt instead.
+func Read(r io.Reader) ([]Snippet, error) {
+	return read(r, true)
+}
+
+// read parses every snippet in r, decoding their code only if withCode is
+// set.
+func read(r io.Reader, withCode bool) ([]Snippet, error) {
+	snippets := []Snippet{}
+	scanner := newScanner(r)
+	for scanner.Scan() {
+		if s, ok := parseLine(scanner.Text(), withCode); ok {
+			snippets = append(snippets, s)
+		}
+	}
+	return snippets, scanner.Err()
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	// A snippet is one line however big its code is, so lift the
+	// scanner's default 64KB limit
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return scanner
+}
+
+// parseLine reads the snippet on one line of a file. It reports false for
+// lines that don't hold one, such as the header. Without withCode the
+// code is left empty and not checked, so Corrupt is never set.
+func parseLine(line string, withCode bool) (Snippet, bool) {
+	// The scanner drops the \r of a \r\n line ending, and fields are
+	// otherwise kept as they are: names can end in spaces, and a field
+	// that fails to decode is written back exactly as read. Only values
+	// that are parsed, not kept, have whitespace around them ignored, so
+	// a hand edited file still reads.
+	parts := strings.Split(line, "|||")
+	if len(parts) < 4 {
+		return Snippet{}, false
+	}
+	id, _ := strconv.Atoi(strings.TrimSpace(parts[0]))
+	s := Snippet{
+		ID:       id,
+		Name:     parts[1],
+		Language: parts[2],
+	}
+	if withCode {
+		if decodedCode, err := decodeField(parts[3]); err == nil {
+			s.Code = string(decodedCode)
+		} else {
+			s.Code = parts[3]
+			s.Corrupt = true
+		}
+	}
+
+	// Anything after the code is optional key=value metadata
+	for _, field := range parts[4:] {
+		key, raw, _ := strings.Cut(field, "=")
+		value := strings.TrimSpace(raw)
+		switch strings.TrimSpace(key) {
+		case "tags":
+			s.Tags = ParseTags(value)
+		case "pinned":
+			s.Pinned = value == "1"
+		case "sensitive":
+			s.Sensitive = value == "1"
+		case "archived":
+			s.Archived = value == "1"
+		case "created":
+			s.CreatedAt, _ = time.Parse(time.RFC3339, value)
+		case "rating":
+			if r, err := strconv.Atoi(value); err == nil && ValidRating(r) {
+				s.Rating = r
+			}
+		case "used":
+			s.LastUsedAt, _ = time.Parse(time.RFC3339, value)
+		case "uid":
+			s.UID = value
+		case "block":
+			if withCode {
+				s.Blocks = append(s.Blocks, parseBlock(raw))
+			}
+		case "source":
+			s.Source = value
+		case "notes":
+			if notes, err := decodeField(raw); err == nil {
+				s.Notes = string(notes)
+			} else {
+				s.Notes = raw
+				s.NotesCorrupt = true
+			}
+		}
+	}
+	return s, true
+}
+
+// decodeField decodes a base64 field, ignoring whitespace around it.
+func decodeField(field string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(field))
+}
+
+// EnsureDir creates dir and any missing parents, so snippet files and
+// logs can be written into it. Everything that needs a data directory goes
+// through here so a failure always reads the same way.
+func EnsureDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("can't create data directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+// Save writes snippets to the file at path, replacing it and creating its
+// directory if needed.
+func Save(path string, snippets []Snippet) error {
+	if err := EnsureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	// Keep the high-water mark of the file being replaced, so deleting
+	// the newest snippet doesn't free its ID. Files from before the
+	// header get theirs worked out from their snippets, once.
+	recorded := RecordedNextID(path)
+	if recorded == 0 {
+		if old, err := Load(path); err == nil {
+			recorded = NextID(old)
+		}
+	}
+	next := max(NextID(snippets), recorded)
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := write(file, snippets, next); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Write encodes snippets in the file format to w.
+func Write(w io.Writer, snippets []Snippet) error {
+	return write(w, snippets, NextID(snippets))
+}
+
+func write(w io.Writer, snippets []Snippet, next int) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s|||next=%d\n", headerPrefix, next)
+	for _, s := range snippets {
+		// Encode the code as base64 to preserve newlines
+		encodedCode := base64.StdEncoding.EncodeToString([]byte(s.Code))
+		if s.Corrupt {
+			encodedCode = s.Code
+		}
+		fmt.Fprintf(bw, "%d|||%s|||%s|||%s", s.ID, field(s.Name), field(s.Language), encodedCode)
+		for _, b := range s.Blocks {
+			if !b.Corrupt {
+				b.Language = field(b.Language)
+			}
+			fmt.Fprintf(bw, "|||block=%s", formatBlock(b))
+		}
+		if len(s.Tags) > 0 {
+			tags := make([]string, len(s.Tags))
+			for i, t := range s.Tags {
+				tags[i] = field(t)
+			}
+			fmt.Fprintf(bw, "|||tags=%s", strings.Join(tags, ","))
+		}
+		if s.Pinned {
+			fmt.Fprint(bw, "|||pinned=1")
+		}
+		if s.Sensitive {
+			fmt.Fprint(bw, "|||sensitive=1")
+		}
+		if s.Archived {
+			fmt.Fprint(bw, "|||archived=1")
+		}
+		if !s.CreatedAt.IsZero() {
+			fmt.Fprintf(bw, "|||created=%s", s.CreatedAt.Format(time.RFC3339))
+		}
+		if s.Rating != 0 {
+			fmt.Fprintf(bw, "|||rating=%d", s.Rating)
+		}
+		if !s.LastUsedAt.IsZero() {
+			fmt.Fprintf(bw, "|||used=%s", s.LastUsedAt.Format(time.RFC3339))
+		}
+		if s.UID != "" {
+			fmt.Fprintf(bw, "|||uid=%s", s.UID)
+		}
+		if s.Source != "" {
+			fmt.Fprintf(bw, "|||source=%s", field(s.Source))
+		}
+		if s.NotesCorrupt {
+			fmt.Fprintf(bw, "|||notes=%s", s.Notes)
+		} else if s.Notes != "" {
+			fmt.Fprintf(bw, "|||notes=%s", base64.StdEncoding.EncodeToString([]byte(s.Notes)))
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// field makes text safe to store as one of a line's fields: line breaks
+// become spaces, and pipes that could be read as a ||| separator, whether
+// inside it or against one at either end, are thinned out.
+func field(text string) string {
+	text = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(text)
+	for strings.Contains(text, "|||") {
+		text = strings.ReplaceAll(text, "|||", "||")
+	}
+	return strings.Trim(text, "|")
+}
+
+// ModTime returns the modification time of the file at path, or the zero
+// time if it doesn't exist.
+func ModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// SaveIfUnchanged writes snippets only if the file still has the
+// modification time since, so a second writer can't be silently
+// overwritten, and returns ErrChanged otherwise. It returns the file's new
+// modification time.
+func SaveIfUnchanged(path string, snippets []Snippet, since time.Time) (time.Time, error) {
+	if !ModTime(path).Equal(since) {
+		return since, ErrChanged
+	}
+	if err := Save(path, snippets); err != nil {
+		return since, err
+	}
+	return ModTime(path), nil
+}
+
+// RecordedNextID returns the next ID recorded in the header of the file at
+// path, or 0 if it has none or can't be read. It can be lower than NextID
+// of the snippets in the file if another tool added some, so take the
+// larger of the two.
+func RecordedNextID(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	// Older files start straight with a snippet, which can be huge
+	br := bufio.NewReader(file)
+	if start, _ := br.Peek(len(headerPrefix)); string(start) != headerPrefix {
+		return 0
+	}
+	line, _ := br.ReadString('\n')
+	fields := strings.Split(strings.TrimRight(line, " \t\r\n"), "|||")
+	if fields[0] != headerPrefix {
+		return 0
+	}
+	for _, field := range fields[1:] {
+		if value, ok := strings.CutPrefix(field, "next="); ok {
+			next, _ := strconv.Atoi(value)
+			return next
+		}
+	}
+	return 0
+}
+
+// NextID returns an ID not used by any of snippets.
+func NextID(snippets []Snippet) int {
+	maxID := 0
+	for _, s := range snippets {
+		if s.ID > maxID {
+			maxID = s.ID
+		}
+	}
+	return maxID + 1
+}
+
+// NormalizeTag is the canonical spelling of a tag: trimmed and lowercase.
+func NormalizeTag(t string) string {
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// ParseTags splits a comma separated list of tags, normalizing them and
+// dropping blanks and duplicates.
+func ParseTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		t = NormalizeTag(t)
+		if t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// Corrupted returns the IDs of snippets with code or notes that couldn't
+// be decoded.
+func Corrupted(snippets []Snippet) []int {
+	var ids []int
+	for _, s := range snippets {
+		if s.Damaged() {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadWriteRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		snippets []Snippet
+	}{
+		{"empty", []Snippet{}},
+		{"plain", []Snippet{{ID: 1, Name: "hello", Language: "go", Code: "fmt.Println(\"hi\")"}}},
+		{"multiline code", []Snippet{{ID: 2, Name: "loop", Language: "sh", Code: "for f in *; do\n\techo \"$f\"\ndone\n"}}},
+		{"name ending in spaces", []Snippet{{ID: 8, Name: "  padded  ", Language: "go ", Code: "x"}}},
+		{"code with separators", []Snippet{{ID: 3, Name: "pipes", Language: "sh", Code: "a ||| b\n=== |"}}},
+		{"metadata", []Snippet{{
+			ID: 4, Name: "full", Language: "python", Code: "print(1)",
+			Tags: []string{"db", "ops"}, Pinned: true, Sensitive: true, Archived: true,
+			CreatedAt: created, Rating: 3, LastUsedAt: created.Add(time.Hour),
+			UID: "abc123", Source: "https://example.com/a", Notes: "line one\nline two",
+			Blocks: []Block{{Language: "sql", Code: "select 1;\n"}},
+		}}},
+		{"several", []Snippet{
+			{ID: 1, Name: "one", Language: "go", Code: "1"},
+			{ID: 7, Name: "seven", Language: "", Code: ""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.snippets); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			got, err := Read(&buf)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.snippets) {
+				t.Errorf("round trip:\n got %+v\nwant %+v", got, tt.snippets)
+			}
+		})
+	}
+}
+
+func TestWriteHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippets []Snippet
+		want     string
+	}{
+		{"empty", nil, "#snipsnap|||next=1\n"},
+		{"after highest id", []Snippet{{ID: 2}, {ID: 9}, {ID: 4}}, "#snipsnap|||next=10\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.snippets); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			header, _, _ := strings.Cut(buf.String(), "\n")
+			if header+"\n" != tt.want {
+				t.Errorf("header = %q, want %q", header+"\n", tt.want)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Snippet
+	}{
+		{"empty input", "", []Snippet{}},
+		{"header only", "#snipsnap|||next=5\n", []Snippet{}},
+		{"no header", "1|||a|||go|||eA==\n", []Snippet{{ID: 1, Name: "a", Language: "go", Code: "x"}}},
+		{
+			"too few fields skipped",
+			"#snipsnap|||next=3\n1|||a|||go\njunk\n\n2|||b|||sh|||eQ==\n",
+			[]Snippet{{ID: 2, Name: "b", Language: "sh", Code: "y"}},
+		},
+		{"unknown fields ignored", "1|||a|||go|||eA==|||later=1\n", []Snippet{{ID: 1, Name: "a", Language: "go", Code: "x"}}},
+		{
+			"whitespace around parsed values ignored",
+			"1 |||a |||go|||eA== \t|||pinned=1 |||rating= 2\n",
+			[]Snippet{{ID: 1, Name: "a ", Language: "go", Code: "x", Pinned: true, Rating: 2}},
+		},
+		{"bad rating dropped", "1|||a|||go|||eA==|||rating=9\n", []Snippet{{ID: 1, Name: "a", Language: "go", Code: "x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read(%q):\n got %+v\nwant %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCRLF(t *testing.T) {
+	input := "#snipsnap|||next=3\r\n1|||first|||go|||bGluZSBvbmUKbGluZSB0d28=|||tags=a,b\r\n2|||second|||sh|||ZWNobyBoaQ==\r\n"
+	got, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []Snippet{
+		{ID: 1, Name: "first", Language: "go", Code: "line one\nline two", Tags: []string{"a", "b"}},
+		{ID: 2, Name: "second", Language: "sh", Code: "echo hi"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Read:\n got %+v\nwant %+v", got, want)
+	}
+	for _, s := range got {
+		if strings.Contains(s.Name, "\r") || strings.Contains(s.Code, "\r") || s.Corrupt {
+			t.Errorf("snippet %d kept a carriage return: %+v", s.ID, s)
+		}
+	}
+}
+
+func TestCorruptLineWrittenBackUnchanged(t *testing.T) {
+	input := "#snipsnap|||next=3\n" +
+		"1|||broken |||go|||not*base64! \t|||block=sh:also *bad\t|||notes=%%% \n" +
+		"2|||fine|||sh|||ZWNobyBoaQ==\n"
+	got, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("read %d snippets, want 2", len(got))
+	}
+	if !got[0].Corrupt || !got[0].NotesCorrupt || !got[0].Blocks[0].Corrupt {
+		t.Errorf("broken snippet not marked corrupt: %+v", got[0])
+	}
+	if got[1].Damaged() {
+		t.Errorf("fine snippet marked damaged: %+v", got[1])
+	}
+	if ids := Corrupted(got); !reflect.DeepEqual(ids, []int{1}) {
+		t.Errorf("Corrupted = %v, want [1]", ids)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, got); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if buf.String() != input {
+		t.Errorf("written back:\n%q\nwant\n%q", buf.String(), input)
+	}
+}
+
+func TestWriteSeparatorsInFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     Snippet
+		fields Snippet
+	}{
+		{
+			"separator in name",
+			Snippet{ID: 1, Name: "a|||b", Language: "go"},
+			Snippet{ID: 1, Name: "a||b", Language: "go"},
+		},
+		{
+			"pipes against the separator",
+			Snippet{ID: 2, Name: "|a||", Language: "||go|"},
+			Snippet{ID: 2, Name: "a", Language: "go"},
+		},
+		{
+			"long run of pipes",
+			Snippet{ID: 3, Name: "x|||||y", Language: "sh"},
+			Snippet{ID: 3, Name: "x||y", Language: "sh"},
+		},
+		{
+			"line breaks",
+			Snippet{ID: 4, Name: "two\nlines\r\nhere\r", Language: "s\nh"},
+			Snippet{ID: 4, Name: "two lines here ", Language: "s h"},
+		},
+		{
+			"tags and source",
+			Snippet{ID: 5, Name: "t", Tags: []string{"a|||b", "c\nd"}, Source: "http://x|||y\n"},
+			Snippet{ID: 5, Name: "t", Tags: []string{"a||b", "c d"}, Source: "http://x||y"},
+		},
+		{
+			"block language",
+			Snippet{ID: 6, Name: "b", Blocks: []Block{{Language: "sql|||x\n", Code: "select 1"}}},
+			Snippet{ID: 6, Name: "b", Blocks: []Block{{Language: "sql||x ", Code: "select 1"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.in.Code, tt.fields.Code = "echo |||\nhi", "echo |||\nhi"
+			tt.in.Notes, tt.fields.Notes = "a ||| note", "a ||| note"
+			tt.in.Rating, tt.fields.Rating = 2, 2
+			tt.in.Pinned, tt.fields.Pinned = true, true
+			tt.in.UID, tt.fields.UID = "uid1", "uid1"
+			var buf bytes.Buffer
+			if err := Write(&buf, []Snippet{tt.in}); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+				t.Fatalf("wrote %d lines, want a header and one snippet:\n%s", lines, buf.String())
+			}
+			got, err := Read(&buf)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if !reflect.DeepEqual(got, []Snippet{tt.fields}) {
+				t.Errorf("read back:\n got %+v\nwant %+v", got, []Snippet{tt.fields})
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// NewUID returns a random (version 4) UUID for Snippet.UID.
+func NewUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// AssignUIDs gives every snippet that lacks a UID a new one, in place, and
+// reports whether it had to. Snippets saved before UIDs existed get
+// theirs the first time they pass through here.
+func AssignUIDs(snippets []Snippet) bool {
+	changed := false
+	for i := range snippets {
+		if snippets[i].UID == "" {
+			snippets[i].UID = NewUID()
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/sahilm/fuzzy"
+
+	"github.com/adammpkins/snipsnap/store"
+)
+
+// tagCount is one row of the tags screen.
+type tagCount struct {
+	Tag   string
+	Count int
+}
+
+// addTag appends t to tags after normalizing it, unless it is blank or
+// already present.
+func addTag(tags []string, t string) []string {
+	t = store.NormalizeTag(t)
+	if t == "" || containsTag(tags, t) {
+		return tags
+	}
+	return append(tags, t)
+}
+
+// suggestTags fuzzy matches query against the tags already used in
+// snippets, leaving out those in chosen. Busier tags win ties.
+func suggestTags(query string, snippets []snippet, chosen []string) []string {
+	query = store.NormalizeTag(query)
+	if query == "" {
+		return nil
+	}
+
+	var candidates []string
+	for _, t := range countTags(snippets) {
+		if !containsTag(chosen, t.Tag) {
+			candidates = append(candidates, t.Tag)
+		}
+	}
+
+	var suggestions []string
+	for _, match := range fuzzy.Find(query, candidates) {
+		suggestions = append(suggestions, match.Str)
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+	}
+	return suggestions
+}
+
+// countTags returns every distinct tag in snippets, most used first and
+// alphabetical among equals.
+func countTags(snippets []snippet) []tagCount {
+	counts := map[string]int{}
+	for _, s := range snippets {
+		for _, t := range s.Tags {
+			counts[t]++
+		}
+	}
+
+	tags := make([]tagCount, 0, len(counts))
+	for t, n := range counts {
+		tags = append(tags, tagCount{Tag: t, Count: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags
+}
+
+// containsTag reports whether tag is in tags.
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyTag reports whether tags includes any of want.
+func hasAnyTag(tags, want []string) bool {
+	for _, t := range want {
+		if containsTag(tags, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// renameTag replaces from with to on every snippet that has it and returns
+// how many snippets changed. A snippet that already had to keeps it once.
+func renameTag(snippets []snippet, from, to string) int {
+	changed := 0
+	for i := range snippets {
+		if !containsTag(snippets[i].Tags, from) {
+			continue
+		}
+		var tags []string
+		for _, t := range snippets[i].Tags {
+			if t == from {
+				t = to
+			}
+			if !containsTag(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+		snippets[i].Tags = tags
+		changed++
+	}
+	return changed
+}
+
+// removeTag drops tag from every snippet and returns how many changed.
+func removeTag(snippets []snippet, tag string) int {
+	changed := 0
+	for i := range snippets {
+		if !containsTag(snippets[i].Tags, tag) {
+			continue
+		}
+		var tags []string
+		for _, t := range snippets[i].Tags {
+			if t != tag {
+				tags = append(tags, t)
+			}
+		}
+		snippets[i].Tags = tags
+		changed++
+	}
+	return changed
+}
+
+// toggleTag adds t to tags, or removes it if it is already there, and
+// reports whether it was added. t is normalized first.
+func toggleTag(tags []string, t string) ([]string, bool) {
+	t = store.NormalizeTag(t)
+	if !containsTag(tags, t) {
+		return addTag(tags, t), true
+	}
+	var kept []string
+	for _, existing := range tags {
+		if existing != t {
+			kept = append(kept, existing)
+		}
+	}
+	return kept, false
+}
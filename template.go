@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// placeholderPattern matches a {{name}} token in snippet code. Names start
+// with a letter or underscore, so Go template actions like {{.Name}} are
+// left alone.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// placeholders returns the distinct placeholder names in code, in the
+// order they first appear.
+func placeholders(code string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(code, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// fillPlaceholders replaces every placeholder in code with its value.
+func fillPlaceholders(code string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(code, func(token string) string {
+		return values[placeholderPattern.FindStringSubmatch(token)[1]]
+	})
+}
+
+// templateFill is a copy waiting on placeholder values, one prompt per
+// name.
+type templateFill struct {
+	id     int
+	code   string
+	names  []string
+	values map[string]string
+	// quit exits after copying, for --pick
+	quit bool
+	// run is the language to run the filled in code as, once it is
+	// copied, or empty to only copy it
+	run string
+}
+
+// copyCode copies text from the snippet with the given ID, first asking
+// for a value for each placeholder in it if there are any. quit exits once
+// the copy is done.
+func (m model) copyCode(id int, text, what string, quit bool) (tea.Model, tea.Cmd) {
+	names := placeholders(text)
+	if len(names) == 0 {
+		return m.finishCopy(id, text, what, quit)
+	}
+	m.fill = &templateFill{id: id, code: text, names: names, values: map[string]string{}, quit: quit}
+	m.navigate("fill")
+	m.input.Placeholder = names[0]
+	m.input.SetValue("")
+	return m, m.input.Focus()
+}
+
+// nextPlaceholder records the typed value and moves on to the next name,
+// copying the filled in code after the last one.
+func (m model) nextPlaceholder() (tea.Model, tea.Cmd) {
+	f := m.fill
+	f.values[f.names[len(f.values)]] = m.input.Value()
+	if len(f.values) < len(f.names) {
+		m.input.Placeholder = f.names[len(f.values)]
+		m.input.SetValue("")
+		return m, nil
+	}
+	m.fill = nil
+	m = m.back()
+	if f.run != "" {
+		name := ""
+		if i := m.findSnippet(f.id); i >= 0 {
+			name = m.snippets[i].Name
+		}
+		return m.confirmRun(f.id, name, f.run, fillPlaceholders(f.code, f.values))
+	}
+	// Values are copied as typed, even if they look like placeholders
+	return m.finishCopy(f.id, fillPlaceholders(f.code, f.values), fmt.Sprintf("snippet with %d placeholders filled", len(f.names)), f.quit)
+}
+
+// finishCopy puts text on the clipboard and records the snippet as used.
+func (m model) finishCopy(id int, text, what string, quit bool) (tea.Model, tea.Cmd) {
+	if err := copyToClipboard(text); err != nil {
+		m.err = fmt.Errorf("copy failed: %w", err)
+		return m, nil
+	}
+	cmd := m.markUsed(id)
+	if quit {
+		// Saving the use is all there is to save, so don't ask about it
+		return m.saveAndQuit()
+	}
+	m.status = "Copied " + what
+	return m, cmd
+}
+
+// fillView renders the placeholder prompts filled so far and the current
+// one.
+func (m model) fillView() string {
+	f := m.fill
+	var s strings.Builder
+	s.WriteString(m.renderTitle("Fill In Template"))
+	s.WriteString("\n\n")
+	for _, name := range f.names[:len(f.values)] {
+		s.WriteString(itemStyle.Render(fmt.Sprintf("%s: %s", name, f.values[name])) + "\n")
+	}
+	s.WriteString(itemStyle.Render(fmt.Sprintf("%s:\n%s", f.names[len(f.values)], m.input.View())) + "\n")
+	s.WriteString(itemStyle.Render(fmt.Sprintf("%d of %d", len(f.values)+1, len(f.names))) + "\n")
+	s.WriteString(m.keyHints())
+	return s.String()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// theme is a named set of colors the styles are built from.
+type theme struct {
+	name    string
+	accent  lipgloss.Color // titles, chips and the selected item
+	text    lipgloss.Color
+	muted   lipgloss.Color // placeholders and footers
+	added   lipgloss.Color
+	removed lipgloss.Color // also errors and confirmations
+}
+
+// themes are the presets config.Theme can name, in the order 'T' cycles
+// through them. The first is the default.
+var themes = []theme{
+	{name: "default", accent: "#7D56F4", text: "#FAFAFA", muted: "#BDBDBD", added: "#04B575", removed: "#FF5F87"},
+	{name: "nord", accent: "#88C0D0", text: "#ECEFF4", muted: "#7B88A1", added: "#A3BE8C", removed: "#BF616A"},
+	{name: "gruvbox", accent: "#FE8019", text: "#EBDBB2", muted: "#A89984", added: "#B8BB26", removed: "#FB4934"},
+	{name: "solarized", accent: "#268BD2", text: "#EEE8D5", muted: "#93A1A1", added: "#859900", removed: "#DC322F"},
+	{name: "mono", accent: "#FFFFFF", text: "#D0D0D0", muted: "#808080", added: "#FFFFFF", removed: "#FFFFFF"},
+}
+
+func init() {
+	applyTheme(themes[0])
+}
+
+// findTheme returns the preset called name.
+func findTheme(name string) (theme, bool) {
+	for _, t := range themes {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return theme{}, false
+}
+
+// nextTheme returns the preset after the one called name.
+func nextTheme(name string) theme {
+	for i, t := range themes {
+		if t.name == name {
+			return themes[(i+1)%len(themes)]
+		}
+	}
+	return themes[0]
+}
+
+// applyTheme rebuilds the package styles from t. Components given copies
+// of them when they were made need restyling too; see model.restyle.
+func applyTheme(t theme) {
+	titleStyle = lipgloss.NewStyle().
+		MarginLeft(2).
+		Foreground(lipgloss.Color("#FAFAFA")).
+		Background(t.accent).
+		Padding(0, 1)
+
+	itemStyle = lipgloss.NewStyle().
+		PaddingLeft(4).
+		Foreground(t.text)
+
+	selectedItemStyle = itemStyle.
+		Foreground(t.accent).
+		Bold(true)
+
+	paginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
+	helpStyle = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
+
+	quitTextStyle = lipgloss.NewStyle().Margin(1, 0, 2, 4)
+
+	inputStyle = lipgloss.NewStyle().
+		Foreground(t.text)
+
+	placeholderStyle = lipgloss.NewStyle().
+		Foreground(t.muted)
+
+	addedLineStyle = lipgloss.NewStyle().
+		PaddingLeft(4).
+		Foreground(t.added)
+
+	removedLineStyle = lipgloss.NewStyle().
+		PaddingLeft(4).
+		Foreground(t.removed)
+
+	chipStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FAFAFA")).
+		Background(t.accent).
+		Padding(0, 1).
+		MarginRight(1)
+
+	footerStyle = lipgloss.NewStyle().
+		PaddingLeft(4).
+		Foreground(t.muted)
+
+	errorStyle = lipgloss.NewStyle().
+		PaddingLeft(4).
+		Foreground(t.removed)
+
+	confirmStyle = lipgloss.NewStyle().
+		Margin(1, 0, 1, 4).
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.removed)
+}
+
+// restyle hands the current styles to the components that keep their own
+// copies, and drops rendered code drawn with the old ones.
+func (m *model) restyle() {
+	m.list.Styles.Title = titleStyle
+	m.list.Styles.PaginationStyle = paginationStyle
+	m.list.Styles.HelpStyle = helpStyle
+	m.input.PlaceholderStyle = placeholderStyle
+	m.input.TextStyle = inputStyle
+	m.search.PlaceholderStyle = placeholderStyle
+	m.search.TextStyle = inputStyle
+	m.render.clear()
+}
+
+// cycleTheme switches to the next preset straight away and remembers it.
+func (m *model) cycleTheme() {
+	t := nextTheme(m.cfg.Theme)
+	m.cfg.Theme = t.name
+	applyTheme(t)
+	m.restyle()
+	m.status = "Theme: " + t.name
+	if err := saveConfigValue("theme", t.name); err != nil {
+		m.err = fmt.Errorf("couldn't remember the theme: %w", err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Build information, normally injected by the release build:
+//
+//	go build -ldflags "-X main.version=v1.0.0 -X main.commit=abc123 -X main.date=2024-09-17"
+//
+// When they are left empty, versionString falls back to what the Go
+// toolchain embedded in the binary.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+func versionString() string {
+	v, c, d := version, commit, date
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if c == "" {
+					c = s.Value
+				}
+			case "vcs.time":
+				if d == "" {
+					d = s.Value
+				}
+			}
+		}
+	}
+
+	if v == "" {
+		v = "dev"
+	}
+	if c == "" {
+		c = "unknown"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return fmt.Sprintf("snipsnap %s (commit %s, built %s)", v, c, d)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Layout goes by display width rather than bytes, so names with CJK
+// characters or emoji, which take two cells, still line up.
+
+// padRight pads s with spaces to width cells.
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-lipgloss.Width(s), 0))
+}
+
+// fitWidth cuts s down to width cells, ending it with an ellipsis if
+// anything was cut. A width of zero or less, from a terminal whose size
+// isn't known yet, leaves s alone.
+func fitWidth(s string, width int) string {
+	if width <= 0 || lipgloss.Width(s) <= width {
+		return s
+	}
+	return ansi.Truncate(s, width, "…")
+}
+
+// codePreview is the first non-blank line of code with its runs of
+// whitespace collapsed, cut down to width cells, for telling snippets
+// apart without opening them.
+func codePreview(code string, width int) string {
+	for _, line := range strings.Split(code, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			return fitWidth(line, width)
+		}
+	}
+	return ""
+}
+
+// lineWidth is how much of the terminal a line indented like itemStyle
+// has, or 0 if the size isn't known yet.
+func (m model) lineWidth() int {
+	if m.width == 0 {
+		return 0
+	}
+	return max(m.width-itemStyle.GetPaddingLeft(), 1)
+}
+
+// wrapHints lists hints on as few lines as fit in width cells, breaking
+// only between hints. A width of zero keeps them on one line.
+func wrapHints(hints []string, width int) string {
+	const sep = " • "
+	var lines []string
+	line := ""
+	for _, h := range hints {
+		switch {
+		case line == "":
+			line = h
+		case width > 0 && lipgloss.Width(line+sep+h) > width:
+			lines = append(lines, line)
+			line = h
+		default:
+			line += sep + h
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestNameWidths(t *testing.T) {
+	tests := []struct {
+		name  string
+		width int
+	}{
+		{"deploy", 6},
+		{"部署", 4},
+		{"日本語のメモ", 12},
+		{"배포 스크립트", 13},
+		{"🚀 deploy", 9},
+		{"🔥🔥", 4},
+		{"café", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lipgloss.Width(tt.name); got != tt.width {
+				t.Fatalf("width = %d, want %d", got, tt.width)
+			}
+			if got := lipgloss.Width(padRight(tt.name, 16)); got != 16 {
+				t.Errorf("padRight to 16 is %d wide", got)
+			}
+			if got := padRight(tt.name, tt.width-1); got != tt.name {
+				t.Errorf("padRight narrower than the name = %q, want it unchanged", got)
+			}
+			if got := fitWidth(tt.name, tt.width); got != tt.name {
+				t.Errorf("fitWidth to its own width = %q, want it unchanged", got)
+			}
+			// A cut never leaves more cells than asked for, even when it
+			// falls in the middle of a wide character
+			for width := 1; width < tt.width; width++ {
+				got := fitWidth(tt.name, width)
+				if w := lipgloss.Width(got); w > width {
+					t.Errorf("fitWidth(%d) = %q, %d wide", width, got, w)
+				}
+				if got == tt.name {
+					t.Errorf("fitWidth(%d) didn't cut", width)
+				}
+			}
+		})
+	}
+}
+
+func TestFitWidthCuts(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"日本語のメモ", 5, "日本…"},
+		{"日本語のメモ", 6, "日本…"},
+		{"🚀🚀🚀", 4, "🚀…"},
+		{"abc", 0, "abc"},
+		{"部署", 2, "…"},
+	}
+	for _, tt := range tests {
+		if got := fitWidth(tt.in, tt.width); got != tt.want {
+			t.Errorf("fitWidth(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestCompactViewFitsWideNames(t *testing.T) {
+	m := testModel(t, 50, 30, []snippet{
+		{ID: 1, Name: "日本語のメモ日本語のメモ日本語のメモ日本語のメモ", Language: "sh", Code: "echo こんにちは世界こんにちは世界こんにちは世界"},
+		{ID: 2, Name: "🚀 deploy 🚀🚀🚀🚀🚀🚀🚀🚀🚀🚀🚀🚀🚀🚀🚀🚀", Language: "sh", Code: "kubectl apply -f 🔥.yaml"},
+		{ID: 3, Name: "배포 스크립트", Language: "sh", Code: "./deploy.sh --all --verbose --and-a-long-tail-of-flags"},
+	})
+	m.navigate("view")
+	m.density = densityCompact
+	for _, line := range strings.Split(m.View(), "\n") {
+		if w := lipgloss.Width(line); w > 50 {
+			t.Errorf("line %q is %d wide, more than the terminal's 50", line, w)
+		}
+	}
+}
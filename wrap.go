@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// hscrollStep is how many cells < and > move the code sideways.
+const hscrollStep = 8
+
+// codeWidth is how much of a line code lines get after the cursor column,
+// or 0 if the terminal size isn't known yet.
+func (m model) codeWidth() int {
+	if m.width == 0 {
+		return 0
+	}
+	return max(m.lineWidth()-2, 1)
+}
+
+// layoutLine fits one display line of code to width. Wrapped, it becomes
+// as many lines as it takes, broken at spaces where it can be; unwrapped,
+// it is cut to the window m.hscroll cells in. A width of zero leaves it
+// alone.
+func (m model) layoutLine(line string, width int) []string {
+	if width <= 0 {
+		return []string{line}
+	}
+	if m.wrap {
+		return strings.Split(ansi.Wrap(line, width, ""), "\n")
+	}
+	return []string{ansi.Truncate(dropCells(line, m.hscroll), width, "")}
+}
+
+// dropCells removes the first n cells of s, which holds no escape codes.
+// A wide character straddling the cut is dropped whole.
+func dropCells(s string, n int) string {
+	for i, r := range s {
+		if n <= 0 {
+			return s[i:]
+		}
+		n -= ansi.StringWidth(string(r))
+	}
+	return ""
+}
+
+// wrapKey describes W by what it will switch to.
+func (m model) wrapKey() key.Binding {
+	if m.wrap {
+		return withHelp(keys.Wrap, "scroll long lines instead")
+	}
+	return keys.Wrap
+}
+
+// toggleWrap switches code lines between wrapping and scrolling sideways.
+func (m *model) toggleWrap() {
+	m.wrap = !m.wrap
+	m.hscroll = 0
+}
+
+// scrollSideways moves unwrapped code by step cells, not past its start.
+func (m *model) scrollSideways(step int) {
+	if m.wrap {
+		m.status = "Lines are wrapped; press W to scroll them instead"
+		return
+	}
+	m.hscroll = max(m.hscroll+step, 0)
+}
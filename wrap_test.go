@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// codeSection returns the lines of view between the entry named name and
+// the separator after it that hold its code.
+func codeSection(t *testing.T, view, name string) []string {
+	t.Helper()
+	lines := strings.Split(view, "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.Contains(line, "Name: "+name) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		t.Fatalf("no entry named %q in:\n%s", name, view)
+	}
+	for start < len(lines) && !strings.Contains(lines[start], "Code:") {
+		start++
+	}
+	var code []string
+	for _, line := range lines[start+1:] {
+		if strings.Contains(line, "-----") {
+			return code
+		}
+		code = append(code, line)
+	}
+	t.Fatalf("entry %q has no end in:\n%s", name, view)
+	return nil
+}
+
+func TestWrapKeepsSnippetsApart(t *testing.T) {
+	first := strings.Repeat("alpha ", 15) + "end1\nshort one"
+	second := strings.Repeat("bravo ", 15) + "end2"
+	m := testModel(t, 50, 60, []snippet{
+		{ID: 1, Name: "one", Language: "sh", Code: first},
+		{ID: 2, Name: "two", Language: "sh", Code: second},
+	})
+	m.navigate("view")
+	m.toggleWrap()
+	view := m.View()
+
+	tests := []struct {
+		name, code, word, other string
+	}{
+		{"one", first, "alpha", "bravo"},
+		{"two", second, "bravo", "alpha"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section := codeSection(t, view, tt.name)
+			if len(section) <= strings.Count(tt.code, "\n")+1 {
+				t.Errorf("code wasn't wrapped: %q", section)
+			}
+			for _, line := range section {
+				if w := lipgloss.Width(line); w > 50 {
+					t.Errorf("line %q is %d wide, more than the terminal", line, w)
+				}
+				if strings.Contains(line, tt.other) {
+					t.Errorf("line %q from the other snippet", line)
+				}
+			}
+			// Every word is drawn once, in order, and only in its own entry
+			if got, want := strings.Fields(strings.Join(section, " ")), strings.Fields(tt.code); strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("drawn words = %q, want %q", got, want)
+			}
+			// Copies are cut from the stored code, so none of the wrapping
+			// gets onto the clipboard
+			if got, want := codeLines(tt.code, 0, 0), strings.Split(tt.code, "\n")[0]; got != want {
+				t.Errorf("copied first line = %q, want %q", got, want)
+			}
+		})
+	}
+	if got := codeLines(first, 0, 5); got != first {
+		t.Errorf("copied whole code = %q, want %q", got, first)
+	}
+}